@@ -21,8 +21,16 @@ import (
 var browsersJsonData []byte
 
 type BrowserResult struct {
-	Browser BrowserConfig `json:"browser"`
-	Error   string        `json:"error"`
+	Browser        BrowserConfig   `json:"browser"`
+	Error          string          `json:"error"`
+	ErrorStack     string          `json:"errorStack"`
+	MatchedHandler *MatchedHandler `json:"matchedHandler"`
+}
+
+// MatchedHandler identifies the config handler that selected the browser
+type MatchedHandler struct {
+	Index  int    `json:"index"`
+	Source string `json:"source"`
 }
 
 type BrowserConfig struct {
@@ -426,3 +434,92 @@ func formatCommand(path string, args []string) string {
 
 	return strings.Join(quotedArgs, " ")
 }
+
+// applicationDirs are the folders searched for apps referred to by name
+var applicationDirs = []string{
+	"/Applications",
+	"/Applications/Utilities",
+	"/System/Applications",
+	"/System/Applications/Utilities",
+	"~/Applications",
+}
+
+// IsKnownApp reports whether a browser name, bundle id or path from a config
+// refers to a browser Finicky knows about or an installed app
+func IsKnownApp(name string, appType string) bool {
+	options, err := ListBrowserOptions()
+	if err == nil {
+		for _, option := range options {
+			if option.ID == name || option.AppName == name {
+				return true
+			}
+		}
+	}
+
+	homeDir, err := util.UserHomeDir()
+	if err != nil {
+		return true
+	}
+
+	switch appType {
+	case "path":
+		_, err := os.Stat(strings.Replace(name, "~", homeDir, 1))
+		return err == nil
+	case "bundleId":
+		output, err := exec.Command("mdfind", fmt.Sprintf("kMDItemCFBundleIdentifier == %q", name)).Output()
+		if err != nil {
+			// Without Spotlight there is no way to tell, so don't report it
+			return true
+		}
+		return strings.TrimSpace(string(output)) != ""
+	default:
+		for _, dir := range applicationDirs {
+			appPath := filepath.Join(strings.Replace(dir, "~", homeDir, 1), name+".app")
+			if _, err := os.Stat(appPath); err == nil {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// CheckProfile returns an error if a profile from a config can't be used with a browser
+func CheckProfile(identifier string, profile string) error {
+	browsersJson, err := getBrowserInfo()
+	if err != nil {
+		return err
+	}
+
+	var matchedBrowser *browserInfo
+	for _, browser := range browsersJson {
+		if browser.ID == identifier || browser.AppName == identifier {
+			matchedBrowser = &browser
+			break
+		}
+	}
+
+	if matchedBrowser == nil || matchedBrowser.Type != "Chromium" {
+		return fmt.Errorf("profile %q is ignored because %s is not a supported Chromium browser", profile, identifier)
+	}
+
+	homeDir, err := util.UserHomeDir()
+	if err != nil {
+		return err
+	}
+
+	localStatePath := filepath.Join(homeDir, "Library/Application Support", matchedBrowser.ConfigDirRelative, "Local State")
+	profiles, err := getProfilesFromLocalState(localStatePath)
+	if err != nil {
+		return fmt.Errorf("could not read profiles for %s: %v", matchedBrowser.AppName, err)
+	}
+
+	var profileNames []string
+	for _, p := range profiles {
+		if p.Path == profile || p.Name == profile {
+			return nil
+		}
+		profileNames = append(profileNames, p.Name)
+	}
+
+	return fmt.Errorf("profile %q not found for %s, available profiles: %s", profile, matchedBrowser.AppName, strings.Join(profileNames, ", "))
+}
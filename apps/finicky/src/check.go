@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"finicky/config"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// runCheck implements `finicky check [path] [-json]`, which validates a config
+// without starting the app and returns the process exit code. Warnings are
+// printed but only errors fail the check.
+func runCheck(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	jsonOutput := flags.Bool("json", false, "Print problems as JSON")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: finicky check [path] [-json]")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	// Allows flags after the path too
+	configPath := flags.Arg(0)
+	if flags.NArg() > 1 {
+		if err := flags.Parse(flags.Args()[1:]); err != nil {
+			return 2
+		}
+	}
+
+	if configPath != "" {
+		absolutePath, err := filepath.Abs(configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid config path: %v\n", err)
+			return 2
+		}
+		configPath = absolutePath
+	}
+
+	result, err := config.Check(embeddedFiles, "finickyConfig", configPath)
+	if err != nil {
+		if *jsonOutput {
+			printJSON(map[string]interface{}{"ok": false, "error": err.Error()})
+		} else {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return 2
+	}
+
+	if *jsonOutput {
+		printJSON(result)
+	} else if len(result.Problems) == 0 {
+		fmt.Printf("%s: no problems found\n", result.ConfigPath)
+	} else {
+		for _, problem := range result.Problems {
+			fmt.Println(problem.Error())
+		}
+		fmt.Printf("%d error(s) and %d warning(s) found in %s\n",
+			result.Count(config.SeverityError), result.Count(config.SeverityWarning), result.ConfigPath)
+	}
+
+	if !result.Ok {
+		return 1
+	}
+	return 0
+}
+
+func printJSON(value interface{}) {
+	encoded, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to encode result: %v\n", err)
+		return
+	}
+	fmt.Println(string(encoded))
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -8,6 +9,9 @@ import (
 	"strings"
 
 	"finicky/util"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
 )
 
 type GeneratedRoute struct {
@@ -38,17 +42,15 @@ func (cfw *ConfigFileWatcher) SaveGeneratedConfig(request GeneratedConfigRequest
 		return nil, fmt.Errorf("default browser is required")
 	}
 
-	homeDir, err := util.UserHomeDir()
+	configPath, err := cfw.generatedConfigPath()
 	if err != nil {
 		return nil, err
 	}
 
-	configPath := cfw.getExistingConfigPathRaw()
-	if configPath == "" {
-		configPath = cfw.preferredConfigPath(homeDir)
+	content, err := buildGeneratedConfigContentFor(configPath, request)
+	if err != nil {
+		return nil, err
 	}
-
-	content := BuildGeneratedConfigContent(request)
 	backupPath, err := backupIfFileExists(configPath)
 	if err != nil {
 		return nil, err
@@ -76,6 +78,98 @@ func (cfw *ConfigFileWatcher) SaveGeneratedConfig(request GeneratedConfigRequest
 	}, nil
 }
 
+// PreviewGeneratedConfig returns the config the builder would save, in the format of the config file it would replace
+func (cfw *ConfigFileWatcher) PreviewGeneratedConfig(request GeneratedConfigRequest) (*PreviewGeneratedConfigResult, error) {
+	configPath, err := cfw.generatedConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := buildGeneratedConfigContentFor(configPath, request)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PreviewGeneratedConfigResult{
+		Ok:      true,
+		Content: content,
+	}, nil
+}
+
+func (cfw *ConfigFileWatcher) generatedConfigPath() (string, error) {
+	homeDir, err := util.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	configPath := cfw.getExistingConfigPathRaw()
+	if configPath == "" {
+		configPath = cfw.preferredConfigPath(homeDir)
+	}
+	if isConfigDir(configPath) {
+		// Write the generated rules as a fragment next to the others
+		configPath = filepath.Join(configPath, generatedFragmentName)
+	}
+
+	return configPath, nil
+}
+
+// buildGeneratedConfigContentFor builds the generated config as YAML, TOML or JSON
+// when replacing a declarative config, and as JavaScript otherwise
+func buildGeneratedConfigContentFor(configPath string, request GeneratedConfigRequest) (string, error) {
+	if !isDeclarativeConfig(configPath) {
+		return BuildGeneratedConfigContent(request), nil
+	}
+
+	generated := generatedDeclarativeConfig{DefaultBrowser: request.DefaultBrowser, Handlers: []generatedDeclarativeHandler{}}
+	for _, route := range request.Routes {
+		patterns := normalizePatterns(route.Patterns)
+		browserValue := strings.TrimSpace(route.Browser)
+		profileValue := strings.TrimSpace(route.Profile)
+		if len(patterns) == 0 || browserValue == "" {
+			continue
+		}
+
+		handler := generatedDeclarativeHandler{Match: patterns, Browser: browserValue}
+		if profileValue != "" {
+			handler.Browser = generatedDeclarativeBrowser{Name: browserValue, Profile: profileValue}
+		}
+		generated.Handlers = append(generated.Handlers, handler)
+	}
+
+	var content []byte
+	var err error
+	switch strings.ToLower(filepath.Ext(configPath)) {
+	case ".toml":
+		content, err = toml.Marshal(generated)
+	case ".json":
+		content, err = json.MarshalIndent(generated, "", "  ")
+		content = append(content, '\n')
+	default:
+		content, err = yaml.Marshal(generated)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed generating config: %w", err)
+	}
+
+	return string(content), nil
+}
+
+type generatedDeclarativeConfig struct {
+	DefaultBrowser string                        `json:"defaultBrowser" yaml:"defaultBrowser" toml:"defaultBrowser"`
+	Handlers       []generatedDeclarativeHandler `json:"handlers" yaml:"handlers" toml:"handlers"`
+}
+
+type generatedDeclarativeHandler struct {
+	Match   []string    `json:"match" yaml:"match" toml:"match"`
+	Browser interface{} `json:"browser" yaml:"browser" toml:"browser"`
+}
+
+type generatedDeclarativeBrowser struct {
+	Name    string `json:"name" yaml:"name" toml:"name"`
+	Profile string `json:"profile" yaml:"profile" toml:"profile"`
+}
+
 func BuildGeneratedConfigContent(request GeneratedConfigRequest) string {
 	builder := strings.Builder{}
 	builder.WriteString("export default {\n")
@@ -21,6 +21,8 @@ type CacheData struct {
 	BundlePath string    `json:"bundlePath"`
 	ModTime    time.Time `json:"modTime"`
 	AppVersion string    `json:"appVersion"` // Store app version with cache data
+	// Inputs maps every local file that went into the bundle to its content hash
+	Inputs map[string]string `json:"inputs"`
 }
 
 // ConfigCache manages persistent caching of bundled configurations
@@ -28,6 +30,7 @@ type ConfigCache struct {
 	cachedBundlePath string
 	cachedConfigPath string
 	cachedModTime    time.Time
+	cachedInputs     map[string]string
 	cachePath        string // Path to store persistent cache information
 	appVersion       string // The current app version
 }
@@ -56,9 +59,9 @@ func NewConfigCache() *ConfigCache {
 func (cc *ConfigCache) GetCachedBundle(configPath string) (string, bool) {
 	// Check if we have a cache and that it's for the current version
 	if cc.cachedBundlePath != "" && cc.cachedConfigPath == configPath {
-		// Check if file has been modified
-		fileInfo, err := os.Stat(configPath)
-		if err == nil && !fileInfo.ModTime().After(cc.cachedModTime) {
+		// Check if file, or any fragment in a config directory, has been modified
+		modTime, err := latestModTime(configPath)
+		if err == nil && !modTime.After(cc.cachedModTime) && cc.inputsUnchanged() {
 			// Verify bundled file still exists
 			if _, err := os.Stat(cc.cachedBundlePath); err == nil {
 				slog.Debug("Using cached bundled config", "path", cc.cachedBundlePath, "version", cc.appVersion)
@@ -71,16 +74,24 @@ func (cc *ConfigCache) GetCachedBundle(configPath string) (string, bool) {
 	return "", false
 }
 
-// UpdateCache updates the cache with a new bundled file
-func (cc *ConfigCache) UpdateCache(configPath, bundlePath string) error {
-	fileInfo, err := os.Stat(configPath)
+// UpdateCache updates the cache with a new bundled file and the local files it was built from
+func (cc *ConfigCache) UpdateCache(configPath, bundlePath string, inputs []string) error {
+	modTime, err := latestModTime(configPath)
 	if err != nil {
 		return fmt.Errorf("failed to stat config file: %w", err)
 	}
 
 	cc.cachedBundlePath = bundlePath
 	cc.cachedConfigPath = configPath
-	cc.cachedModTime = fileInfo.ModTime()
+	cc.cachedModTime = modTime
+	cc.cachedInputs = make(map[string]string, len(inputs))
+	for _, input := range inputs {
+		hash, err := getFileHash(input)
+		if err != nil {
+			return fmt.Errorf("failed to hash config input: %w", err)
+		}
+		cc.cachedInputs[input] = hash
+	}
 
 	// Save cache to disk for persistence between runs
 	cc.saveCache()
@@ -92,6 +103,7 @@ func (cc *ConfigCache) Clear() {
 	slog.Debug("Clearing configuration cache")
 	cc.cachedBundlePath = ""
 	cc.cachedConfigPath = ""
+	cc.cachedInputs = nil
 	// Update cache file to reflect cleared cache
 	cc.saveCache()
 }
@@ -137,6 +149,7 @@ func (cc *ConfigCache) loadCache() {
 		cc.cachedConfigPath = cacheData.ConfigPath
 		cc.cachedBundlePath = cacheData.BundlePath
 		cc.cachedModTime = cacheData.ModTime
+		cc.cachedInputs = cacheData.Inputs
 		slog.Debug("Loaded config cache",
 			"configPath", cacheData.ConfigPath,
 			"bundlePath", cacheData.BundlePath,
@@ -163,6 +176,7 @@ func (cc *ConfigCache) saveCache() {
 		BundlePath: cc.cachedBundlePath,
 		ModTime:    cc.cachedModTime,
 		AppVersion: cc.appVersion, // Include app version in the cache data
+		Inputs:     cc.cachedInputs,
 	}
 
 	// Marshal to JSON
@@ -182,6 +196,29 @@ func (cc *ConfigCache) saveCache() {
 	slog.Debug("Saved config cache", "path", cc.cachePath, "version", cc.appVersion)
 }
 
+// Inputs returns the local files the cached bundle was built from
+func (cc *ConfigCache) Inputs() []string {
+	inputs := make([]string, 0, len(cc.cachedInputs))
+	for input := range cc.cachedInputs {
+		inputs = append(inputs, input)
+	}
+	sort.Strings(inputs)
+	return inputs
+}
+
+// inputsUnchanged reports whether every file the cached bundle was built from
+// still has the same content, so edits to imported modules invalidate the cache
+func (cc *ConfigCache) inputsUnchanged() bool {
+	for input, cachedHash := range cc.cachedInputs {
+		hash, err := getFileHash(input)
+		if err != nil || hash != cachedHash {
+			slog.Debug("Config input changed, rebundling", "path", input)
+			return false
+		}
+	}
+	return true
+}
+
 // fileInfo represents a file with its path and modification time
 type fileInfo struct {
 	path    string
@@ -284,6 +321,11 @@ func getFinickyCacheDir() string {
 	return finickyCacheDir
 }
 
+// CachePath returns the path of a file in the Finicky cache directory, next to the config cache
+func CachePath(fileName string) string {
+	return getCachePath("", fileName)
+}
+
 // getCachePath returns a path within the finicky cache directory with optional subdirectories
 func getCachePath(subDir string, fileName string) string {
 	cacheDir := getFinickyCacheDir()
@@ -313,6 +355,15 @@ func getContentHash(content string, length int) string {
 	return hash
 }
 
+// getFileHash returns the content hash of a file
+func getFileHash(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return getContentHash(string(data), 16), nil
+}
+
 // GetTransformedPath returns a deterministic path for a transformed file
 func GetTransformedPath(content string) string {
 	appVersion := version.GetCurrentVersion()
@@ -323,6 +374,9 @@ func GetTransformedPath(content string) string {
 	return getCachePath("transform", fmt.Sprintf("finicky_babel_%s.js", contentHash))
 }
 
+// bundleFilePrefix starts the file name of every bundle
+const bundleFilePrefix = "finicky_bundle_"
+
 // GetBundlePath returns a deterministic path for a bundled file
 func GetBundlePath(configPath string) string {
 	appVersion := version.GetCurrentVersion()
@@ -330,5 +384,5 @@ func GetBundlePath(configPath string) string {
 	// Combine config path and app version for the hash
 	pathWithVersion := configPath + "|version:" + appVersion
 	configHash := getContentHash(pathWithVersion, 8)
-	return getCachePath("", fmt.Sprintf("finicky_bundle_%s.js", configHash))
+	return getCachePath("", fmt.Sprintf("%s%s.js", bundleFilePrefix, configHash))
 }
@@ -0,0 +1,271 @@
+package config
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"finicky/browser"
+	"finicky/schedule"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// Severities of the problems reported by Check
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// SourceError is a config problem at a position in a source file
+type SourceError struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Severity string `json:"severity,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (e *SourceError) Error() string {
+	message := e.Message
+	if e.Severity != "" {
+		message = fmt.Sprintf("%s: %s", e.Severity, e.Message)
+	}
+
+	switch {
+	case e.File == "" && e.Line == 0:
+		return message
+	case e.File == "":
+		return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, message)
+	case e.Line == 0:
+		return fmt.Sprintf("%s: %s", e.File, message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, message)
+}
+
+// SourceErrors collects all problems found in a config file
+type SourceErrors []*SourceError
+
+func (e SourceErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// withSeverity sets the severity of every problem that doesn't have one yet
+func (e SourceErrors) withSeverity(severity string) SourceErrors {
+	for _, err := range e {
+		if err.Severity == "" {
+			err.Severity = severity
+		}
+	}
+	return e
+}
+
+// CheckResult is the outcome of checking a config. Ok is false when any
+// problem is an error, warnings alone don't fail a check.
+type CheckResult struct {
+	ConfigPath string       `json:"configPath"`
+	Ok         bool         `json:"ok"`
+	Problems   SourceErrors `json:"problems"`
+}
+
+// Count returns the number of problems with a severity
+func (r *CheckResult) Count(severity string) int {
+	count := 0
+	for _, problem := range r.Problems {
+		if problem.Severity == severity {
+			count++
+		}
+	}
+	return count
+}
+
+// configProblem mirrors ConfigProblem in the config API
+type configProblem struct {
+	Path    []interface{} `json:"path"`
+	Source  string        `json:"source"`
+	Message string        `json:"message"`
+}
+
+// browserReference mirrors BrowserReference in the config API
+type browserReference struct {
+	Path    []interface{} `json:"path"`
+	Source  string        `json:"source"`
+	Name    string        `json:"name"`
+	AppType string        `json:"appType"`
+	Profile string        `json:"profile"`
+}
+
+// Check bundles, runs and validates a config the way Finicky loads it and
+// returns every problem found, located in the original source files. An empty
+// configPath checks the config Finicky would pick up.
+func Check(embeddedFiles embed.FS, namespace string, configPath string) (*CheckResult, error) {
+	cfw := &ConfigFileWatcher{
+		customConfigPath: configPath,
+		namespace:        namespace,
+		watchedInputs:    make(map[string]bool),
+	}
+
+	resolvedPath, err := cfw.GetConfigPath(false)
+	if err != nil {
+		return nil, err
+	}
+
+	problems := cfw.check(embeddedFiles, resolvedPath).withSeverity(SeverityError)
+	if problems == nil {
+		problems = SourceErrors{}
+	}
+
+	result := &CheckResult{
+		ConfigPath: resolvedPath,
+		Problems:   problems,
+	}
+	result.Ok = result.Count(SeverityError) == 0
+	return result, nil
+}
+
+func (cfw *ConfigFileWatcher) check(embeddedFiles embed.FS, configPath string) SourceErrors {
+	bundlePath, _, err := cfw.bundleConfigPath(configPath)
+	if err != nil {
+		var errs SourceErrors
+		if errors.As(err, &errs) {
+			return errs
+		}
+		return SourceErrors{{File: configPath, Message: err.Error()}}
+	}
+
+	vm := &VM{
+		runtime:   goja.New(),
+		namespace: cfw.namespace,
+		clock:     schedule.SystemClock,
+	}
+	if err := vm.load(embeddedFiles, bundlePath); err != nil {
+		return SourceErrors{{File: configPath, Message: err.Error()}}
+	}
+
+	var problems []configProblem
+	if err := vm.exportJSON("finickyConfigAPI.getConfigProblems(finalConfig)", &problems); err != nil {
+		return SourceErrors{{File: configPath, Message: err.Error()}}
+	}
+
+	errs := locateProblems(configPath, problems)
+
+	// Browsers can only be listed from a config that matches the schema
+	if len(errs) > 0 {
+		return errs
+	}
+
+	if _, vm.unknownOptions, err = vm.parseOptions(); err != nil {
+		return SourceErrors{{File: configPath, Message: err.Error()}}
+	}
+
+	references, err := vm.browserReferences()
+	if err != nil {
+		return SourceErrors{{File: configPath, Message: err.Error()}}
+	}
+	errs = checkBrowsers(configPath, references)
+
+	findings, err := vm.lintFindings()
+	if err != nil {
+		errs = append(errs, &SourceError{File: configPath, Severity: SeverityWarning, Message: fmt.Sprintf("failed to lint config: %v", err)})
+	}
+	errs = append(errs, locateProblems(configPath, findings).withSeverity(SeverityWarning)...)
+	return append(errs, optionWarnings(configPath, vm.unknownOptions).withSeverity(SeverityWarning)...)
+}
+
+// browserReferences returns the browsers the config refers to
+func (vm *VM) browserReferences() ([]browserReference, error) {
+	var references []browserReference
+	err := vm.exportJSON("finickyConfigAPI.getBrowserReferences(finalConfig)", &references)
+	return references, err
+}
+
+// checkBrowsers reports browsers the config refers to that aren't installed, and
+// Chromium profiles that don't exist
+func checkBrowsers(configPath string, references []browserReference) SourceErrors {
+	// The same browser is usually referred to by many handlers, and looking it up can take a while
+	problems := make(map[[3]string]string)
+
+	var errs SourceErrors
+	for _, reference := range references {
+		key := [3]string{reference.Name, reference.AppType, reference.Profile}
+		message, checked := problems[key]
+		if !checked {
+			if !browser.IsKnownApp(reference.Name, reference.AppType) {
+				message = fmt.Sprintf("unknown browser %q, it is neither a browser Finicky knows nor an installed app", reference.Name)
+			} else if reference.Profile != "" {
+				if err := browser.CheckProfile(reference.Name, reference.Profile); err != nil {
+					message = err.Error()
+				}
+			}
+			problems[key] = message
+		}
+
+		if message != "" {
+			errs = append(errs, locateProblem(configPath, reference.Source, reference.Path, message))
+		}
+	}
+
+	return errs
+}
+
+// exportJSON evaluates an expression in the VM and decodes its result into target
+func (vm *VM) exportJSON(expression string, target interface{}) error {
+	value, err := vm.runtime.RunString(fmt.Sprintf("JSON.stringify(%s)", expression))
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal([]byte(value.String()), target)
+}
+
+// locateProblems locates the problems found in a config in its files
+func locateProblems(configPath string, problems []configProblem) SourceErrors {
+	var errs SourceErrors
+	for _, problem := range problems {
+		errs = append(errs, locateProblem(configPath, problem.Source, problem.Path, problem.Message))
+	}
+	return errs
+}
+
+// locateProblem creates an error for a problem at a path into the config,
+// located in the file the value was defined in
+func locateProblem(configPath string, source string, path []interface{}, message string) *SourceError {
+	file := configPath
+	if source != "" {
+		file = filepath.Join(configPath, source)
+	}
+
+	if formattedPath := formatConfigPath(path); formattedPath != "" {
+		message = fmt.Sprintf("%s: %s", formattedPath, message)
+	}
+
+	sourceErr := &SourceError{File: file, Message: message}
+	if !isConfigDir(file) {
+		sourceErr.Line, sourceErr.Column = locateConfigPath(file, path)
+	}
+
+	return sourceErr
+}
+
+// formatConfigPath formats a path into the config like handlers[2].browser
+func formatConfigPath(path []interface{}) string {
+	var builder strings.Builder
+	for _, segment := range path {
+		switch key := segment.(type) {
+		case float64:
+			builder.WriteString(fmt.Sprintf("[%d]", int(key)))
+		default:
+			if builder.Len() > 0 {
+				builder.WriteString(".")
+			}
+			builder.WriteString(fmt.Sprint(key))
+		}
+	}
+	return builder.String()
+}
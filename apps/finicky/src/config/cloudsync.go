@@ -46,6 +46,10 @@ func (cfw *ConfigFileWatcher) EnableICloudSync() (*CloudSyncResult, error) {
 		currentPath = preferredPath
 	}
 
+	if isConfigDir(currentPath) {
+		return nil, fmt.Errorf("icloud sync is not supported for config directories")
+	}
+
 	ext := filepath.Ext(currentPath)
 	if ext != ".ts" {
 		ext = ".js"
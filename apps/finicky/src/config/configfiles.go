@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/evanw/esbuild/pkg/api"
@@ -24,6 +25,10 @@ type ConfigFileWatcher struct {
 
 	// Cache manager
 	cache *ConfigCache
+
+	// Modules imported by the config that are watched in addition to the config itself
+	inputsMutex   sync.Mutex
+	watchedInputs map[string]bool
 }
 
 // NewConfigFileWatcher creates a new file watcher for configuration files
@@ -39,6 +44,7 @@ func NewConfigFileWatcher(customConfigPath string, namespace string, configChang
 		namespace:          namespace,
 		configChangeNotify: configChangeNotify,
 		cache:              NewConfigCache(),
+		watchedInputs:      make(map[string]bool),
 	}
 
 	go cfw.StartWatching()
@@ -75,6 +81,15 @@ func (cfw *ConfigFileWatcher) GetConfigPaths() []string {
 			"~/.config/finicky.ts",
 			"~/.config/finicky/finicky.js",
 			"~/.config/finicky/finicky.ts",
+			// Declarative configs without any scripting
+			"~/.finicky.yaml",
+			"~/.finicky.toml",
+			"~/.finicky.json",
+			"~/.config/finicky/finicky.yaml",
+			"~/.config/finicky/finicky.toml",
+			"~/.config/finicky/finicky.json",
+			// Directory of config fragments merged in lexical order
+			"~/.config/finicky/conf.d",
 		)
 	}
 
@@ -124,51 +139,150 @@ func (cfw *ConfigFileWatcher) BundleConfig() (string, string, error) {
 
 	// Check if we can use cached bundle
 	if bundlePath, cacheHit := cfw.cache.GetCachedBundle(configPath); cacheHit {
+		cfw.watchInputs(configPath, cfw.cache.Inputs())
 		return bundlePath, configPath, nil
 	}
 
-	// Apply babel transformation
-	transformedPath, err := cfw.babelTransform(configPath)
+	bundlePath, inputs, err := cfw.bundleConfigPath(configPath)
 	if err != nil {
 		return "", configPath, err
 	}
 
-	slog.Debug("Bundling config")
+	cfw.watchInputs(configPath, inputs)
+
+	// Update cache
+	originalConfigPath, err := cfw.GetConfigPath(false)
+	if err == nil {
+		cfw.cache.UpdateCache(originalConfigPath, bundlePath, inputs)
+	}
+
+	return bundlePath, configPath, nil
+}
+
+// bundleConfigPath bundles a config file or config directory and returns the
+// bundle path and the local files that went into it
+func (cfw *ConfigFileWatcher) bundleConfigPath(configPath string) (string, []string, error) {
+	if isConfigDir(configPath) {
+		return cfw.bundleFragments(configPath)
+	}
+
+	result, err := cfw.bundle(configPath, cfw.namespace)
+	if err != nil {
+		return "", nil, err
+	}
 
 	// Use a deterministic filename to help with caching
-	bundlePath := GetBundlePath(transformedPath)
-
-	result := api.Build(api.BuildOptions{
-		EntryPoints: []string{transformedPath},
-		Outfile:     bundlePath,
-		Bundle:      true,
-		Write:       true,
-		LogLevel:    api.LogLevelError,
-		Platform:    api.PlatformNeutral,
-		Target:      api.ES2015,
-		Format:      api.FormatIIFE,
-		GlobalName:  cfw.namespace,
-		Loader: map[string]api.Loader{
-			".ts.symlink": api.LoaderTS,
-			".js.symlink": api.LoaderJS,
-		},
-	})
+	bundlePath := GetBundlePath(result.transformedPath)
+	if err := os.WriteFile(bundlePath, withInlineSourceMap(result.contents, result.sourceMap), 0644); err != nil {
+		return "", nil, fmt.Errorf("failed writing bundle: %w", err)
+	}
+
+	return bundlePath, result.inputs, nil
+}
+
+// bundleResult is the output of bundling a single config file
+type bundleResult struct {
+	contents []byte
+	// sourceMap maps the bundle back to the original files
+	sourceMap []byte
+	// transformedPath is the babel output the bundle was built from
+	transformedPath string
+	// inputs are the local files that went into the bundle, including the config file
+	// itself, with installed packages as their package.json
+	inputs []string
+}
+
+// bundle transforms and bundles a single config file into an IIFE assigned to globalName
+func (cfw *ConfigFileWatcher) bundle(configPath string, globalName string) (*bundleResult, error) {
+	var declarativeSource string
+	transformedPath := configPath
+	if isDeclarativeConfig(configPath) {
+		// YAML, TOML and JSON configs are converted to a module in Go and need no babel transform
+		// Only the merged config of a config directory has to be complete
+		source, err := declarativeModule(configPath, globalName == fragmentGlobalName)
+		if err != nil {
+			return nil, err
+		}
+		declarativeSource = source
+	} else {
+		// Apply babel transformation
+		var err error
+		transformedPath, err = cfw.babelTransform(configPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	slog.Debug("Bundling config", "path", configPath)
+
+	buildOptions := api.BuildOptions{
+		Bundle:     true,
+		Write:      false,
+		Metafile:   true,
+		LogLevel:   api.LogLevelError,
+		Platform:   api.PlatformNeutral,
+		Target:     api.ES2015,
+		Format:     api.FormatIIFE,
+		GlobalName: globalName,
+		Loader:     configLoaders,
+		// Report paths relative to the config directory and resolve packages
+		// from a node_modules directory next to it
+		AbsWorkingDir: filepath.Dir(configPath),
+		NodePaths:     nodeModulesPaths(configPath),
+		MainFields:    []string{"module", "main"},
+		Plugins:       []api.Plugin{nodeBuiltinsPlugin()},
+		// Nothing is written, the output file only anchors the source map paths
+		Sourcemap: api.SourceMapExternal,
+		Outfile:   filepath.Join(filepath.Dir(configPath), "finicky-bundle.js"),
+	}
+
+	if declarativeSource != "" {
+		buildOptions.Stdin = &api.StdinOptions{
+			Contents:   declarativeSource,
+			ResolveDir: filepath.Dir(configPath),
+			Sourcefile: configPath,
+			Loader:     api.LoaderJS,
+		}
+	} else if transformedPath != configPath {
+		// The babel output lives in the cache directory, so resolve its imports
+		// relative to the original config file instead
+		transformed, err := os.ReadFile(transformedPath)
+		if err != nil {
+			return nil, fmt.Errorf("error reading transformed config: %w", err)
+		}
+		buildOptions.Stdin = &api.StdinOptions{
+			Contents:   string(transformed),
+			ResolveDir: filepath.Dir(configPath),
+			Sourcefile: configPath,
+			Loader:     api.LoaderJS,
+		}
+	} else {
+		buildOptions.EntryPoints = []string{transformedPath}
+	}
+
+	result := api.Build(buildOptions)
 
 	if len(result.Errors) > 0 {
-		var errorTexts []string
-		for _, err := range result.Errors {
-			errorTexts = append(errorTexts, err.Text)
+		return nil, fmt.Errorf("build errors: %w", buildSourceErrors(result.Errors, buildOptions.AbsWorkingDir))
+	}
+
+	bundled := &bundleResult{
+		transformedPath: transformedPath,
+		inputs:          metafileInputs(result.Metafile, buildOptions.AbsWorkingDir),
+	}
+	for _, outputFile := range result.OutputFiles {
+		if strings.HasSuffix(outputFile.Path, ".map") {
+			bundled.sourceMap = absoluteSourceMap(outputFile.Contents, filepath.Dir(buildOptions.Outfile))
+		} else {
+			bundled.contents = outputFile.Contents
 		}
-		return "", configPath, fmt.Errorf("build errors: %s", strings.Join(errorTexts, ", "))
 	}
 
-	// Update cache
-	originalConfigPath, err := cfw.GetConfigPath(false)
-	if err == nil {
-		cfw.cache.UpdateCache(originalConfigPath, bundlePath)
+	if bundled.contents == nil {
+		return nil, fmt.Errorf("build produced no output")
 	}
 
-	return bundlePath, configPath, nil
+	return bundled, nil
 }
 
 func (cfw *ConfigFileWatcher) babelTransform(configPath string) (string, error) {
@@ -193,10 +307,15 @@ func (cfw *ConfigFileWatcher) babelTransform(configPath string) (string, error)
 		"plugins": []string{
 			"transform-named-capturing-groups-regex",
 		},
+		// Keeps line numbers in build errors pointing at the original config
+		"retainLines": true,
+		// esbuild picks up the inline source map and chains it into the bundle's
+		"sourceMaps":     "inline",
+		"sourceFileName": filepath.Base(configPath),
 	})
 
 	if err != nil {
-		return "", err
+		return "", babelSourceError(configPath, err)
 	}
 
 	resBytes, err := io.ReadAll(res)
@@ -205,8 +324,9 @@ func (cfw *ConfigFileWatcher) babelTransform(configPath string) (string, error)
 	}
 	resString := string(resBytes)
 
-	// Get a deterministic path for the transformed file
-	transformedPath := GetTransformedPath(configString)
+	// Get a deterministic path for the transformed file. The source map names
+	// the config file, so the name is part of the key.
+	transformedPath := GetTransformedPath(filepath.Base(configPath) + "|" + configString)
 
 	// Check if transformed file already exists
 	if _, err := os.Stat(transformedPath); err == nil {
@@ -316,6 +436,17 @@ func (cfw *ConfigFileWatcher) StartWatching() error {
 				if !ok {
 					return fmt.Errorf("watcher closed")
 				}
+				if event.Name != configPath && cfw.isWatchedInput(event.Name) {
+					cfw.handleDependencyEvent(event)
+					break
+				}
+				// Changes inside a config directory are fragments being edited, added or removed
+				if event.Name != configPath && isConfigDir(configPath) {
+					if isFragmentFile(event.Name) {
+						cfw.handleDependencyEvent(event)
+					}
+					break
+				}
 				err := cfw.handleConfigFileEvent(event)
 				if err != nil {
 					return err
@@ -365,6 +496,65 @@ func (cfw *ConfigFileWatcher) handleConfigFileEvent(event fsnotify.Event) error
 	return nil
 }
 
+// watchInputs watches the local modules a config was bundled from, so that
+// editing an imported file reloads the config like editing the config itself
+func (cfw *ConfigFileWatcher) watchInputs(configPath string, inputs []string) {
+	cfw.inputsMutex.Lock()
+	defer cfw.inputsMutex.Unlock()
+
+	configDir := ""
+	if isConfigDir(configPath) {
+		configDir = configPath
+	}
+
+	current := make(map[string]bool, len(inputs))
+	for _, input := range inputs {
+		// The config file and fragments directly in a config directory are already watched
+		if input == configPath || filepath.Dir(input) == configDir {
+			continue
+		}
+		current[input] = true
+		if err := cfw.watcher.Add(input); err != nil {
+			slog.Debug("Error watching config input", "path", input, "error", err)
+		}
+	}
+
+	for input := range cfw.watchedInputs {
+		if !current[input] {
+			if err := cfw.watcher.Remove(input); err != nil {
+				slog.Debug("Error removing watch on config input", "path", input, "error", err)
+			}
+		}
+	}
+
+	if len(current) > 0 {
+		slog.Debug("Watching config inputs", "count", len(current))
+	}
+	cfw.watchedInputs = current
+}
+
+func (cfw *ConfigFileWatcher) isWatchedInput(path string) bool {
+	cfw.inputsMutex.Lock()
+	defer cfw.inputsMutex.Unlock()
+	return cfw.watchedInputs[path]
+}
+
+// handleDependencyEvent processes events for modules imported by the config and
+// fragments in a config directory. Unlike the config file itself, removing one of
+// them is just another config change.
+func (cfw *ConfigFileWatcher) handleDependencyEvent(event fsnotify.Event) {
+	if event.Op == fsnotify.Chmod {
+		return
+	}
+
+	slog.Debug("Configuration dependency changed", "path", event.Name, "op", event.Op.String())
+	cfw.cache.Clear()
+
+	// Add a small delay to avoid rapid reloading
+	time.Sleep(500 * time.Millisecond)
+	cfw.configChangeNotify <- struct{}{}
+}
+
 // resolveSymlink resolves a symlink to its target file path
 // If the path is not a symlink, it returns the original path
 func resolveSymlink(path string) (string, error) {
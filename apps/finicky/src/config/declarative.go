@@ -0,0 +1,400 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// declarativeOptionType returns how an option of a declarative config is validated,
+// from the type of its field in Options, or an empty string for unknown options
+func declarativeOptionType(name string) string {
+	optionsType := reflect.TypeOf(Options{})
+	for i := range optionsType.NumField() {
+		field := optionsType.Field(i)
+		if strings.Split(field.Tag.Get("json"), ",")[0] != name {
+			continue
+		}
+
+		switch field.Type {
+		case reflect.TypeOf(false):
+			return "boolean"
+		case reflect.TypeOf(""):
+			return "string"
+		case reflect.TypeOf(0):
+			return "number"
+		case reflect.TypeOf([]string{}):
+			return "list"
+		case reflect.TypeOf(map[string]map[string]string{}):
+			return "headers"
+		case reflect.TypeOf(map[string][]string{}):
+			return "domainLists"
+		}
+	}
+	return ""
+}
+
+var browserAppTypes = []string{"appName", "bundleId", "path", "none"}
+
+var regexFlagsPattern = regexp.MustCompile(`^[dgimsuy]*$`)
+
+// isDeclarativeConfig reports whether a config file is YAML, TOML or JSON rather than a script
+func isDeclarativeConfig(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".toml", ".json":
+		return true
+	}
+	return false
+}
+
+// parseDeclarativeConfig reads and validates a YAML, TOML or JSON config file, or
+// a fragment of a config directory when fragment is set
+func parseDeclarativeConfig(configPath string, fragment bool) (*declNode, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %w", err)
+	}
+
+	root, err := parseDeclarativeDocument(configPath, data)
+	if err != nil {
+		if sourceErr, ok := err.(*SourceError); ok {
+			sourceErr.File = configPath
+			return nil, SourceErrors{sourceErr}
+		}
+		return nil, err
+	}
+
+	if errs := validateDeclarativeConfig(root, fragment); len(errs) > 0 {
+		for _, sourceErr := range errs {
+			sourceErr.File = configPath
+		}
+		return nil, errs
+	}
+
+	return root, nil
+}
+
+// parseDeclarativeDocument parses a YAML, TOML or JSON config without validating it
+func parseDeclarativeDocument(configPath string, data []byte) (*declNode, error) {
+	if strings.ToLower(filepath.Ext(configPath)) == ".toml" {
+		return parseTOMLDocument(data)
+	}
+	return parseYAMLDocument(data)
+}
+
+// declarativeModule converts a YAML, TOML or JSON config file into a JavaScript
+// module with the same shape as a script config
+func declarativeModule(configPath string, fragment bool) (string, error) {
+	root, err := parseDeclarativeConfig(configPath, fragment)
+	if err != nil {
+		return "", err
+	}
+
+	var builder strings.Builder
+	builder.WriteString("export default {\n")
+
+	for i, key := range root.keys {
+		value := root.values[i]
+		builder.WriteString(fmt.Sprintf("  %s: ", key.value))
+
+		switch key.value {
+		case "handlers", "rewrite":
+			builder.WriteString("[\n")
+			for _, rule := range value.values {
+				builder.WriteString("    {")
+				for j, ruleKey := range rule.keys {
+					if j > 0 {
+						builder.WriteString(", ")
+					}
+					builder.WriteString(fmt.Sprintf("%s: ", ruleKey.value))
+					if ruleKey.value == "match" {
+						builder.WriteString(matcherJS(rule.values[j]))
+					} else {
+						builder.WriteString(jsonJS(rule.values[j]))
+					}
+				}
+				builder.WriteString("},\n")
+			}
+			builder.WriteString("  ]")
+		default:
+			builder.WriteString(jsonJS(value))
+		}
+
+		builder.WriteString(",\n")
+	}
+
+	builder.WriteString("};\n")
+	return builder.String(), nil
+}
+
+// matcherJS converts a validated matcher into JavaScript, turning regex maps into RegExp objects
+func matcherJS(node *declNode) string {
+	switch node.kind {
+	case declList:
+		matchers := make([]string, len(node.values))
+		for i, item := range node.values {
+			matchers[i] = matcherJS(item)
+		}
+		return "[" + strings.Join(matchers, ", ") + "]"
+	case declMap:
+		flags := &declNode{kind: declScalar, value: ""}
+		if flagsNode := node.get("flags"); flagsNode != nil {
+			flags = flagsNode
+		}
+		return fmt.Sprintf("new RegExp(%s, %s)", jsonJS(node.get("regex")), jsonJS(flags))
+	}
+	return jsonJS(node)
+}
+
+func jsonJS(node *declNode) string {
+	encoded, err := json.Marshal(node.toValue())
+	if err != nil {
+		return "null"
+	}
+	return string(encoded)
+}
+
+// checkJSRegex compiles a pattern with the RegExp of JavaScript, which the config
+// uses, so patterns only JavaScript supports like backreferences are accepted
+func checkJSRegex(pattern string, flags string) error {
+	runtime := goja.New()
+	_, err := runtime.New(runtime.Get("RegExp"), runtime.ToValue(pattern), runtime.ToValue(flags))
+	var exception *goja.Exception
+	if errors.As(err, &exception) {
+		return errors.New(strings.TrimPrefix(exception.Value().String(), "SyntaxError: "))
+	}
+	return err
+}
+
+// validateDeclarativeConfig checks a parsed config against the config shape and returns every problem found.
+// Fragments of a config directory only set part of the config, so they may leave out defaultBrowser.
+func validateDeclarativeConfig(root *declNode, fragment bool) SourceErrors {
+	var errs SourceErrors
+	report := func(node *declNode, format string, args ...interface{}) {
+		errs = append(errs, &SourceError{Line: node.line, Column: node.column, Message: fmt.Sprintf(format, args...)})
+	}
+
+	expectKind := func(node *declNode, name string, kind declKind, typeName string) bool {
+		if node.kind != kind {
+			report(node, "%s must be a %s, got %s", name, typeName, node.typeName())
+			return false
+		}
+		return true
+	}
+
+	expectString := func(node *declNode, name string) bool {
+		if _, ok := node.value.(string); !ok || node.kind != declScalar {
+			report(node, "%s must be a string, got %s", name, node.typeName())
+			return false
+		}
+		return true
+	}
+
+	var validateMatcher func(node *declNode, name string, allowList bool)
+	validateMatcher = func(node *declNode, name string, allowList bool) {
+		switch node.kind {
+		case declList:
+			if !allowList {
+				report(node, "%s can't contain nested lists", name)
+				return
+			}
+			if len(node.values) == 0 {
+				report(node, "%s must not be empty", name)
+			}
+			for i, item := range node.values {
+				validateMatcher(item, fmt.Sprintf("%s[%d]", name, i), false)
+			}
+		case declMap:
+			for i, key := range node.keys {
+				switch key.value {
+				case "regex", "flags":
+					if expectString(node.values[i], fmt.Sprintf("%s.%s", name, key.value)) && key.value == "flags" {
+						if !regexFlagsPattern.MatchString(node.values[i].value.(string)) {
+							report(node.values[i], "%s.flags contains invalid regular expression flags", name)
+						}
+					}
+				default:
+					report(key, "unknown key %q in %s, expected regex or flags", key.value, name)
+				}
+			}
+			regexNode := node.get("regex")
+			if regexNode == nil {
+				report(node, "%s must have a regex", name)
+			} else if pattern, ok := regexNode.value.(string); ok {
+				// Invalid flags are already reported, so the pattern is checked without them
+				flags := ""
+				if flagsNode := node.get("flags"); flagsNode != nil {
+					if value, ok := flagsNode.value.(string); ok && regexFlagsPattern.MatchString(value) {
+						flags = value
+					}
+				}
+				if err := checkJSRegex(pattern, flags); err != nil {
+					report(regexNode, "%s.regex is not a valid regular expression: %s", name, err)
+				}
+			}
+		default:
+			if !expectString(node, name) {
+				return
+			}
+			if node.value.(string) == "" {
+				report(node, "%s must not be empty", name)
+			}
+		}
+	}
+
+	validateBrowser := func(node *declNode, name string) {
+		switch node.kind {
+		case declNull:
+			// null means the url isn't opened at all
+		case declMap:
+			for i, key := range node.keys {
+				value := node.values[i]
+				keyName := fmt.Sprintf("%s.%s", name, key.value)
+				switch key.value {
+				case "name", "profile":
+					expectString(value, keyName)
+				case "appType":
+					if expectString(value, keyName) && !slices.Contains(browserAppTypes, value.value.(string)) {
+						report(value, "%s must be one of %s", keyName, strings.Join(browserAppTypes, ", "))
+					}
+				case "openInBackground":
+					if _, ok := value.value.(bool); !ok {
+						report(value, "%s must be a boolean, got %s", keyName, value.typeName())
+					}
+				case "args":
+					if expectKind(value, keyName, declList, "list of strings") {
+						for j, arg := range value.values {
+							expectString(arg, fmt.Sprintf("%s[%d]", keyName, j))
+						}
+					}
+				default:
+					report(key, "unknown key %q in %s, expected name, profile, appType, openInBackground or args", key.value, name)
+				}
+			}
+			if node.get("name") == nil {
+				report(node, "%s must have a name", name)
+			}
+		default:
+			expectString(node, name)
+		}
+	}
+
+	validateRules := func(node *declNode, name string, target string) {
+		if !expectKind(node, name, declList, "list") {
+			return
+		}
+		for i, rule := range node.values {
+			ruleName := fmt.Sprintf("%s[%d]", name, i)
+			if !expectKind(rule, ruleName, declMap, "map") {
+				continue
+			}
+			for j, key := range rule.keys {
+				value := rule.values[j]
+				keyName := fmt.Sprintf("%s.%s", ruleName, key.value)
+				switch key.value {
+				case "match":
+					validateMatcher(value, keyName, true)
+				case target:
+					if target == "browser" {
+						validateBrowser(value, keyName)
+					} else {
+						expectString(value, keyName)
+					}
+				default:
+					report(key, "unknown key %q in %s, expected match or %s", key.value, ruleName, target)
+				}
+			}
+			if rule.get("match") == nil {
+				report(rule, "%s must have a match", ruleName)
+			}
+			if rule.get(target) == nil {
+				report(rule, "%s must have a %s", ruleName, target)
+			}
+		}
+	}
+
+	if !expectKind(root, "config", declMap, "map") {
+		return errs
+	}
+
+	for i, key := range root.keys {
+		value := root.values[i]
+		switch key.value {
+		case "defaultBrowser":
+			validateBrowser(value, "defaultBrowser")
+		case "handlers":
+			validateRules(value, "handlers", "browser")
+		case "rewrite":
+			validateRules(value, "rewrite", "url")
+		case "options":
+			if !expectKind(value, "options", declMap, "map") {
+				continue
+			}
+			for j, optionKey := range value.keys {
+				option := value.values[j]
+				optionName := fmt.Sprintf("options.%s", optionKey.value)
+				switch declarativeOptionType(optionKey.value.(string)) {
+				case "boolean":
+					if _, ok := option.value.(bool); !ok {
+						report(option, "%s must be a boolean, got %s", optionName, option.typeName())
+					}
+				case "string":
+					expectString(option, optionName)
+				case "number":
+					if number, ok := option.value.(int64); !ok || number < 0 {
+						report(option, "%s must be a non-negative whole number, got %v", optionName, jsonJS(option))
+					}
+				case "list":
+					if expectKind(option, optionName, declList, "list of strings") {
+						for k, item := range option.values {
+							expectString(item, fmt.Sprintf("%s[%d]", optionName, k))
+						}
+					}
+				case "domainLists":
+					if !expectKind(option, optionName, declMap, "map of domains") {
+						continue
+					}
+					for k, domain := range option.keys {
+						domainName := fmt.Sprintf("%s.%s", optionName, domain.value)
+						if expectKind(option.values[k], domainName, declList, "list of strings") {
+							for l, item := range option.values[k].values {
+								expectString(item, fmt.Sprintf("%s[%d]", domainName, l))
+							}
+						}
+					}
+				case "headers":
+					if !expectKind(option, optionName, declMap, "map of domains") {
+						continue
+					}
+					for k, domain := range option.keys {
+						domainName := fmt.Sprintf("%s.%s", optionName, domain.value)
+						headers := option.values[k]
+						if expectKind(headers, domainName, declMap, "map of headers") {
+							for l, header := range headers.keys {
+								expectString(headers.values[l], fmt.Sprintf("%s.%s", domainName, header.value))
+							}
+						}
+					}
+				default:
+					report(optionKey, "unknown option %q, expected one of %s", optionKey.value, strings.Join(optionNames, ", "))
+				}
+			}
+		default:
+			report(key, "unknown key %q, expected defaultBrowser, handlers, rewrite or options", key.value)
+		}
+	}
+
+	if root.get("defaultBrowser") == nil && !fragment {
+		report(root, "defaultBrowser is required")
+	}
+
+	return errs
+}
@@ -0,0 +1,380 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+	"gopkg.in/yaml.v3"
+)
+
+type declKind int
+
+const (
+	declNull declKind = iota
+	declScalar
+	declMap
+	declList
+)
+
+// declNode is a parsed YAML, TOML or JSON value that remembers where it came from
+type declNode struct {
+	kind   declKind
+	value  interface{} // string, bool, int64 or float64 for scalars
+	keys   []*declNode // map keys, in document order
+	values []*declNode // map values or list items
+	line   int
+	column int
+}
+
+func (n *declNode) typeName() string {
+	switch n.kind {
+	case declNull:
+		return "null"
+	case declMap:
+		return "map"
+	case declList:
+		return "list"
+	}
+	switch n.value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	default:
+		return "number"
+	}
+}
+
+// get returns the value for a map key, or nil if it isn't set
+func (n *declNode) get(key string) *declNode {
+	for i, k := range n.keys {
+		if k.value == key {
+			return n.values[i]
+		}
+	}
+	return nil
+}
+
+// set adds a map entry, failing on duplicate keys
+func (n *declNode) set(key *declNode, value *declNode) error {
+	if n.get(key.value.(string)) != nil {
+		return &SourceError{Line: key.line, Column: key.column, Message: fmt.Sprintf("duplicate key %q", key.value)}
+	}
+	n.keys = append(n.keys, key)
+	n.values = append(n.values, value)
+	return nil
+}
+
+// toValue converts the node into plain Go values suitable for JSON encoding
+func (n *declNode) toValue() interface{} {
+	switch n.kind {
+	case declScalar:
+		return n.value
+	case declList:
+		items := make([]interface{}, len(n.values))
+		for i, item := range n.values {
+			items[i] = item.toValue()
+		}
+		return items
+	case declMap:
+		entries := make(map[string]interface{}, len(n.keys))
+		for i, key := range n.keys {
+			entries[key.value.(string)] = n.values[i].toValue()
+		}
+		return entries
+	}
+	return nil
+}
+
+var yamlLinePattern = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// parseYAMLDocument parses YAML, or JSON since it is a subset of YAML
+func parseYAMLDocument(data []byte) (*declNode, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		if matches := yamlLinePattern.FindStringSubmatch(err.Error()); matches != nil {
+			line, _ := strconv.Atoi(matches[1])
+			return nil, &SourceError{Line: line, Column: 1, Message: matches[2]}
+		}
+		return nil, &SourceError{Line: 1, Column: 1, Message: strings.TrimPrefix(err.Error(), "yaml: ")}
+	}
+
+	if len(document.Content) == 0 {
+		return &declNode{kind: declMap, line: 1, column: 1}, nil
+	}
+
+	return convertYAMLNode(document.Content[0])
+}
+
+func convertYAMLNode(node *yaml.Node) (*declNode, error) {
+	result := &declNode{line: node.Line, column: node.Column}
+
+	switch node.Kind {
+	case yaml.AliasNode:
+		return convertYAMLNode(node.Alias)
+
+	case yaml.MappingNode:
+		result.kind = declMap
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyNode := node.Content[i]
+			if keyNode.Kind != yaml.ScalarNode {
+				return nil, &SourceError{Line: keyNode.Line, Column: keyNode.Column, Message: "map keys must be strings"}
+			}
+			value, err := convertYAMLNode(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			key := &declNode{kind: declScalar, value: keyNode.Value, line: keyNode.Line, column: keyNode.Column}
+			if err := result.set(key, value); err != nil {
+				return nil, err
+			}
+		}
+
+	case yaml.SequenceNode:
+		result.kind = declList
+		for _, itemNode := range node.Content {
+			item, err := convertYAMLNode(itemNode)
+			if err != nil {
+				return nil, err
+			}
+			result.values = append(result.values, item)
+		}
+
+	case yaml.ScalarNode:
+		var value interface{}
+		if err := node.Decode(&value); err != nil {
+			return nil, &SourceError{Line: node.Line, Column: node.Column, Message: err.Error()}
+		}
+		switch v := value.(type) {
+		case nil:
+			result.kind = declNull
+		case int:
+			result.kind = declScalar
+			result.value = int64(v)
+		case string, bool, int64, float64:
+			result.kind = declScalar
+			result.value = v
+		default:
+			// Timestamps and other YAML types are kept as written
+			result.kind = declScalar
+			result.value = node.Value
+		}
+
+	default:
+		return nil, &SourceError{Line: node.Line, Column: node.Column, Message: "unsupported YAML value"}
+	}
+
+	return result, nil
+}
+
+// parseTOMLDocument parses TOML with positions for every key and value
+func parseTOMLDocument(data []byte) (*declNode, error) {
+	parser := unstable.Parser{}
+	parser.Reset(data)
+
+	position := func(node *unstable.Node, fallback *declNode) (int, int) {
+		if node == nil || node.Raw.Length == 0 {
+			return fallback.line, fallback.column
+		}
+		shape := parser.Shape(node.Raw)
+		return shape.Start.Line, shape.Start.Column
+	}
+
+	keyPath := func(iterator unstable.Iterator, fallback *declNode) []*declNode {
+		var keys []*declNode
+		for iterator.Next() {
+			keyNode := iterator.Node()
+			key := &declNode{kind: declScalar, value: string(keyNode.Data)}
+			key.line, key.column = position(keyNode, fallback)
+			keys = append(keys, key)
+		}
+		return keys
+	}
+
+	// descend walks a dotted key from a table, creating tables as needed. Arrays of
+	// tables continue in their last element.
+	descend := func(table *declNode, keys []*declNode) (*declNode, error) {
+		for _, key := range keys {
+			next := table.get(key.value.(string))
+			if next == nil {
+				next = &declNode{kind: declMap, line: key.line, column: key.column}
+				if err := table.set(key, next); err != nil {
+					return nil, err
+				}
+			}
+			if next.kind == declList && len(next.values) > 0 {
+				next = next.values[len(next.values)-1]
+			}
+			if next.kind != declMap {
+				return nil, &SourceError{Line: key.line, Column: key.column, Message: fmt.Sprintf("key %q is already defined as a %s", key.value, next.typeName())}
+			}
+			table = next
+		}
+		return table, nil
+	}
+
+	// definedTables are the tables defined by a header or inline, which can't be defined again
+	definedTables := map[*declNode]bool{}
+
+	var convert func(node *unstable.Node, fallback *declNode) (*declNode, error)
+	convert = func(node *unstable.Node, fallback *declNode) (*declNode, error) {
+		result := &declNode{kind: declScalar}
+		result.line, result.column = position(node, fallback)
+		raw := string(node.Data)
+
+		switch node.Kind {
+		case unstable.String:
+			result.value = raw
+		case unstable.Bool:
+			result.value = raw == "true"
+		case unstable.Integer:
+			value, err := strconv.ParseInt(raw, 0, 64)
+			if err != nil {
+				return nil, &SourceError{Line: result.line, Column: result.column, Message: fmt.Sprintf("invalid integer %q", raw)}
+			}
+			result.value = value
+		case unstable.Float:
+			value, err := strconv.ParseFloat(strings.ReplaceAll(raw, "_", ""), 64)
+			if err != nil {
+				return nil, &SourceError{Line: result.line, Column: result.column, Message: fmt.Sprintf("invalid float %q", raw)}
+			}
+			result.value = value
+		case unstable.LocalDate, unstable.LocalTime, unstable.LocalDateTime, unstable.DateTime:
+			result.value = raw
+		case unstable.Array:
+			result.kind = declList
+			children := node.Children()
+			for children.Next() {
+				item, err := convert(children.Node(), result)
+				if err != nil {
+					return nil, err
+				}
+				result.values = append(result.values, item)
+			}
+		case unstable.InlineTable:
+			result.kind = declMap
+			definedTables[result] = true
+			children := node.Children()
+			for children.Next() {
+				keyValue := children.Node()
+				keys := keyPath(keyValue.Key(), result)
+				table, err := descend(result, keys[:len(keys)-1])
+				if err != nil {
+					return nil, err
+				}
+				value, err := convert(keyValue.Value(), keys[len(keys)-1])
+				if err != nil {
+					return nil, err
+				}
+				if err := table.set(keys[len(keys)-1], value); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			return nil, &SourceError{Line: result.line, Column: result.column, Message: fmt.Sprintf("unsupported TOML value %s", node.Kind)}
+		}
+
+		return result, nil
+	}
+
+	root := &declNode{kind: declMap, line: 1, column: 1}
+	current := root
+
+	for parser.NextExpression() {
+		expression := parser.Expression()
+
+		switch expression.Kind {
+		case unstable.KeyValue:
+			keys := keyPath(expression.Key(), current)
+			table, err := descend(current, keys[:len(keys)-1])
+			if err != nil {
+				return nil, err
+			}
+			value, err := convert(expression.Value(), keys[len(keys)-1])
+			if err != nil {
+				return nil, err
+			}
+			if err := table.set(keys[len(keys)-1], value); err != nil {
+				return nil, err
+			}
+
+		case unstable.Table:
+			keys := keyPath(expression.Key(), root)
+			table, err := descend(root, keys)
+			if err != nil {
+				return nil, err
+			}
+			// Tables can only be defined once, though a key path can create them before
+			if definedTables[table] {
+				last := keys[len(keys)-1]
+				names := make([]string, len(keys))
+				for i, key := range keys {
+					names[i] = key.value.(string)
+				}
+				return nil, &SourceError{Line: last.line, Column: last.column, Message: fmt.Sprintf("duplicate table %q", strings.Join(names, "."))}
+			}
+			definedTables[table] = true
+			current = table
+
+		case unstable.ArrayTable:
+			keys := keyPath(expression.Key(), root)
+			parent, err := descend(root, keys[:len(keys)-1])
+			if err != nil {
+				return nil, err
+			}
+			last := keys[len(keys)-1]
+			list := parent.get(last.value.(string))
+			if list == nil {
+				list = &declNode{kind: declList, line: last.line, column: last.column}
+				if err := parent.set(last, list); err != nil {
+					return nil, err
+				}
+			} else if list.kind != declList {
+				return nil, &SourceError{Line: last.line, Column: last.column, Message: fmt.Sprintf("key %q is already defined as a %s", last.value, list.typeName())}
+			}
+			current = &declNode{kind: declMap, line: last.line, column: last.column}
+			list.values = append(list.values, current)
+		}
+	}
+
+	if err := parser.Error(); err != nil {
+		var parserErr *unstable.ParserError
+		if errors.As(err, &parserErr) && parserErr.Highlight != nil {
+			// The highlight is a subslice of the input, so its offset follows from the capacities
+			offset := cap(data) - cap(parserErr.Highlight)
+			line, column := offsetPosition(data, offset)
+			return nil, &SourceError{Line: line, Column: column, Message: parserErr.Message}
+		}
+		return nil, &SourceError{Line: 1, Column: 1, Message: err.Error()}
+	}
+
+	// The stable decoder enforces the rules of TOML the walk above doesn't, like
+	// reopening a table defined inline, though not always with a position
+	var decoded map[string]interface{}
+	if err := toml.Unmarshal(data, &decoded); err != nil {
+		line, column := 1, 1
+		var decodeErr *toml.DecodeError
+		if errors.As(err, &decodeErr) {
+			line, column = decodeErr.Position()
+		}
+		return nil, &SourceError{Line: line, Column: column, Message: strings.TrimPrefix(err.Error(), "toml: ")}
+	}
+
+	return root, nil
+}
+
+// offsetPosition converts a byte offset into a 1-based line and column
+func offsetPosition(data []byte, offset int) (int, int) {
+	if offset < 0 || offset > len(data) {
+		return 1, 1
+	}
+	lead := data[:offset]
+	line := strings.Count(string(lead), "\n") + 1
+	column := offset - strings.LastIndex(string(lead), "\n")
+	return line, column
+}
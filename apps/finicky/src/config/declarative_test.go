@@ -0,0 +1,143 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDeclarativeModule(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		expected []string
+		errors   []string
+	}{
+		{
+			name: "yaml",
+			file: "finicky.yaml",
+			content: `defaultBrowser: Safari
+handlers:
+  - match: ["*.example.com/*", {regex: "^https://work", flags: i}]
+    browser: {name: Google Chrome, profile: Work}
+`,
+			expected: []string{`defaultBrowser: "Safari"`, `new RegExp("^https://work", "i")`, `"profile":"Work"`},
+		},
+		{
+			name:     "javascript only regex",
+			file:     "finicky.yaml",
+			content:  "defaultBrowser: Safari\nhandlers:\n  - match: {regex: '(a)\\1b'}\n    browser: Firefox\n",
+			expected: []string{`new RegExp("(a)\\1b", "")`},
+		},
+		{
+			name: "toml",
+			file: "finicky.toml",
+			content: `defaultBrowser = "Safari"
+
+[[rewrite]]
+match = "example.org/*"
+url = "https://example.com"
+`,
+			expected: []string{`{match: "example.org/*", url: "https://example.com"}`},
+		},
+		{
+			name:     "json",
+			file:     "finicky.json",
+			content:  "{\n\t\"defaultBrowser\": null,\n\t\"options\": {\"logRequests\": true}\n}\n",
+			expected: []string{`defaultBrowser: null`, `options: {"logRequests":true}`},
+		},
+		{
+			name: "validation errors",
+			file: "finicky.yaml",
+			content: `defaultBrowser: 3
+handlers:
+  - match: {regx: a}
+    browser: {name: Firefox, appType: app}
+  - match: {regex: "a("}
+    browser: Firefox
+options:
+  logRequests: "yes"
+`,
+			errors: []string{
+				"finicky.yaml:1:17: defaultBrowser must be a string, got number",
+				`finicky.yaml:3:13: unknown key "regx" in handlers[0].match, expected regex or flags`,
+				"finicky.yaml:3:12: handlers[0].match must have a regex",
+				"finicky.yaml:4:39: handlers[0].browser.appType must be one of appName, bundleId, path, none",
+				"finicky.yaml:5:20: handlers[1].match.regex is not a valid regular expression: Unterminated group",
+				"finicky.yaml:8:16: options.logRequests must be a boolean, got string",
+			},
+		},
+		{
+			name:    "toml syntax error",
+			file:    "finicky.toml",
+			content: "defaultBrowser = \"Safari\"\ndefaultBrowser = \"Firefox\"\n",
+			errors:  []string{`finicky.toml:2:1: duplicate key "defaultBrowser"`},
+		},
+		{
+			name:    "toml table defined twice",
+			file:    "finicky.toml",
+			content: "defaultBrowser = \"Safari\"\n\n[options]\nhideIcon = true\n\n[options]\nlogRequests = true\n",
+			errors:  []string{`finicky.toml:6:2: duplicate table "options"`},
+		},
+		{
+			name:    "toml table reopened after an inline table",
+			file:    "finicky.toml",
+			content: "defaultBrowser = \"Safari\"\noptions = { hideIcon = true }\n\n[options]\nlogRequests = true\n",
+			errors:  []string{`finicky.toml:4:2: duplicate table "options"`},
+		},
+		{
+			name:    "unknown option",
+			file:    "finicky.yaml",
+			content: "defaultBrowser: Safari\noptions:\n  logRequest: true\n",
+			errors:  []string{`finicky.yaml:3:3: unknown option "logRequest", expected one of ` + strings.Join(optionNames, ", ")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(configPath, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("failed to write config: %v", err)
+			}
+
+			module, err := declarativeModule(configPath, false)
+			if len(tt.errors) > 0 {
+				errs, ok := err.(SourceErrors)
+				if !ok {
+					t.Fatalf("declarativeModule() error = %v, want SourceErrors", err)
+				}
+				if len(errs) != len(tt.errors) {
+					t.Fatalf("declarativeModule() returned %d errors, want %d:\n%v", len(errs), len(tt.errors), errs)
+				}
+				for i, expected := range tt.errors {
+					if got := strings.TrimPrefix(errs[i].Error(), filepath.Dir(configPath)+"/"); got != expected {
+						t.Errorf("error %d = %q, want %q", i, got, expected)
+					}
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("declarativeModule() error = %v", err)
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(module, expected) {
+					t.Errorf("declarativeModule() = %s, want it to contain %s", module, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestDeclarativeOptionType(t *testing.T) {
+	for _, name := range optionNames {
+		if declarativeOptionType(name) == "" {
+			t.Errorf("declarativeOptionType(%q) is empty, options of its type can't be set in declarative configs", name)
+		}
+	}
+	if got := declarativeOptionType("logRequest"); got != "" {
+		t.Errorf("declarativeOptionType() of an unknown option = %q, want it empty", got)
+	}
+}
@@ -0,0 +1,194 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// fragmentGlobalName is the global name each fragment bundle is assigned to
+// inside its own function scope before being merged
+const fragmentGlobalName = "finickyConfigFragment"
+
+// generatedFragmentName is the fragment the config builder writes in config directory mode
+const generatedFragmentName = "90-config-builder.js"
+
+// isConfigDir reports whether the config path is a directory of config fragments (conf.d)
+func isConfigDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// manifestFiles are package and tooling files that can sit next to the fragments
+// of a config directory without being config themselves
+var manifestFiles = map[string]bool{
+	"package.json":        true,
+	"package-lock.json":   true,
+	"npm-shrinkwrap.json": true,
+	"pnpm-lock.yaml":      true,
+	"pnpm-workspace.yaml": true,
+	"tsconfig.json":       true,
+	"jsconfig.json":       true,
+	"deno.json":           true,
+	"bunfig.toml":         true,
+}
+
+// isFragmentFile reports whether a file in a config directory is a config fragment
+func isFragmentFile(path string) bool {
+	name := filepath.Base(path)
+	if strings.HasPrefix(name, ".") || manifestFiles[strings.ToLower(name)] {
+		return false
+	}
+
+	switch filepath.Ext(name) {
+	case ".js", ".mjs", ".ts":
+		return true
+	}
+	return isDeclarativeConfig(name)
+}
+
+// listFragments returns the config fragments in a directory in lexical order
+func listFragments(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config directory: %w", err)
+	}
+
+	var fragments []string
+	for _, entry := range entries {
+		if entry.IsDir() || !isFragmentFile(entry.Name()) {
+			continue
+		}
+		fragments = append(fragments, filepath.Join(dir, entry.Name()))
+	}
+
+	return fragments, nil
+}
+
+// bundleFragments bundles each fragment in a config directory individually and
+// combines them into a single script that merges them in lexical order. It
+// returns the bundle path and the local files of all fragments.
+func (cfw *ConfigFileWatcher) bundleFragments(dir string) (string, []string, error) {
+	fragments, err := listFragments(dir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(fragments) == 0 {
+		return "", nil, fmt.Errorf("no config files found in %s", dir)
+	}
+
+	slog.Debug("Bundling config fragments", "dir", dir, "count", len(fragments))
+
+	// Bundles every file first, as data and helpers imported by other fragments
+	// are only known once those are bundled and aren't fragments themselves
+	results := make([]*bundleResult, len(fragments))
+	errs := make([]error, len(fragments))
+	fragmentInputs := make([][]string, len(fragments))
+	for i, fragment := range fragments {
+		results[i], errs[i] = cfw.bundle(fragment, fragmentGlobalName)
+		if errs[i] == nil {
+			fragmentInputs[i] = results[i].inputs
+		}
+	}
+	imported := importedFragments(fragments, fragmentInputs)
+
+	var inputs []string
+	var names []string
+	var merged []*bundleResult
+	for i, fragment := range fragments {
+		if imported[fragment] {
+			slog.Debug("Skipping config file imported by another fragment", "path", fragment)
+			continue
+		}
+		if errs[i] != nil {
+			return "", nil, fmt.Errorf("%s: %w", filepath.Base(fragment), errs[i])
+		}
+		inputs = append(inputs, results[i].inputs...)
+		names = append(names, filepath.Base(fragment))
+		merged = append(merged, results[i])
+	}
+
+	bundlePath := GetBundlePath(dir)
+	if err := os.WriteFile(bundlePath, mergeFragmentBundles(cfw.namespace, names, merged), 0644); err != nil {
+		return "", nil, fmt.Errorf("failed writing bundle: %w", err)
+	}
+
+	return bundlePath, inputs, nil
+}
+
+// mergeFragmentBundles combines the bundles of fragments into a single script
+// assigned to namespace that merges their configs in order, with an index source
+// map pointing back at the files of each fragment
+func mergeFragmentBundles(namespace string, names []string, results []*bundleResult) []byte {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("var %s = (function () {\n", namespace))
+	builder.WriteString("var fragments = [];\n")
+
+	var sections []sourceMapSection
+	for i, result := range results {
+		builder.WriteString("(function () {\n")
+		if result.sourceMap != nil {
+			section := sourceMapSection{Map: result.sourceMap}
+			section.Offset.Line = strings.Count(builder.String(), "\n")
+			sections = append(sections, section)
+		}
+		builder.Write(result.contents)
+		builder.WriteString(fmt.Sprintf("fragments.push({ name: %s, config: %s });\n", quoteJS(names[i]), fragmentGlobalName))
+		builder.WriteString("})();\n")
+	}
+
+	builder.WriteString("return { default: finickyConfigAPI.mergeConfigFragments(fragments) };\n")
+	builder.WriteString("})();\n")
+
+	return withInlineSourceMap([]byte(builder.String()), indexSourceMap(sections))
+}
+
+// importedFragments returns the files of a config directory that another fragment
+// imports, given the local inputs of each fragment's bundle
+func importedFragments(fragments []string, fragmentInputs [][]string) map[string]bool {
+	imported := map[string]bool{}
+	for i, inputs := range fragmentInputs {
+		for _, input := range inputs {
+			if input != fragments[i] && slices.Contains(fragments, input) {
+				imported[input] = true
+			}
+		}
+	}
+	return imported
+}
+
+// latestModTime returns the modification time of a config file, or for a config
+// directory the latest modification time of the directory and its fragments
+func latestModTime(configPath string) (time.Time, error) {
+	info, err := os.Stat(configPath)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	latest := info.ModTime()
+	if !info.IsDir() {
+		return latest, nil
+	}
+
+	fragments, err := listFragments(configPath)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for _, fragment := range fragments {
+		fragmentInfo, err := os.Stat(fragment)
+		if err != nil {
+			continue
+		}
+		if fragmentInfo.ModTime().After(latest) {
+			latest = fragmentInfo.ModTime()
+		}
+	}
+
+	return latest, nil
+}
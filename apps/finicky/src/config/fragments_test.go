@@ -0,0 +1,75 @@
+package config
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestIsFragmentFile(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"/conf.d/10-browsers.js", true},
+		{"/conf.d/20-rules.ts", true},
+		{"/conf.d/30-work.yaml", true},
+		{"/conf.d/40-options.toml", true},
+		{"/conf.d/50-handlers.json", true},
+		{"/conf.d/.hidden.js", false},
+		{"/conf.d/package.json", false},
+		{"/conf.d/package-lock.json", false},
+		{"/conf.d/tsconfig.json", false},
+		{"/conf.d/pnpm-lock.yaml", false},
+		{"/conf.d/README.md", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := isFragmentFile(tt.path); got != tt.expected {
+				t.Errorf("isFragmentFile(%q) = %v, want %v", tt.path, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestImportedFragments(t *testing.T) {
+	fragments := []string{"/conf.d/10-work.js", "/conf.d/20-home.js", "/conf.d/domains.json", "/conf.d/helpers.js"}
+	fragmentInputs := [][]string{
+		{"/conf.d/10-work.js", "/conf.d/domains.json", "/conf.d/helpers.js"},
+		{"/conf.d/20-home.js", "/conf.d/helpers.js", "/shared/browsers.js"},
+		// The data file fails to bundle as a config by itself
+		nil,
+		{"/conf.d/helpers.js"},
+	}
+
+	got := slices.Sorted(maps.Keys(importedFragments(fragments, fragmentInputs)))
+	expected := []string{"/conf.d/domains.json", "/conf.d/helpers.js"}
+	if !slices.Equal(got, expected) {
+		t.Errorf("importedFragments() = %v, want %v", got, expected)
+	}
+}
+
+func TestDeclarativeFragment(t *testing.T) {
+	fragmentPath := filepath.Join(t.TempDir(), "30-work.yaml")
+	content := "handlers:\n  - match: \"*.work.example.com/*\"\n    browser: Google Chrome\n"
+	if err := os.WriteFile(fragmentPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	module, err := declarativeModule(fragmentPath, true)
+	if err != nil {
+		t.Fatalf("declarativeModule() of a fragment error = %v", err)
+	}
+	if strings.Contains(module, "defaultBrowser") {
+		t.Errorf("declarativeModule() = %s, want no defaultBrowser", module)
+	}
+
+	// The same file on its own isn't a complete config
+	if _, err := declarativeModule(fragmentPath, false); err == nil || !strings.Contains(err.Error(), "defaultBrowser is required") {
+		t.Errorf("declarativeModule() of a config error = %v, want defaultBrowser is required", err)
+	}
+}
@@ -0,0 +1,36 @@
+package config
+
+import "fmt"
+
+// Lint looks for likely mistakes in the loaded config, like handlers that can
+// never match or browsers that aren't installed, and returns them as warnings
+// located in the files of the config at configPath. It reads the config from the
+// VM right away, and returns a function doing the slow part, looking up installed
+// apps and locating the mistakes in the files, which doesn't use the VM and can
+// run in the background while it evaluates urls.
+func (vm *VM) Lint(configPath string) func() SourceErrors {
+	findings, findingsErr := vm.lintFindings()
+	references, referencesErr := vm.browserReferences()
+	unknownOptions := vm.unknownOptions
+
+	return func() SourceErrors {
+		var warnings SourceErrors
+		if findingsErr != nil {
+			warnings = append(warnings, &SourceError{File: configPath, Message: fmt.Sprintf("failed to lint config: %v", findingsErr)})
+		}
+		warnings = append(warnings, locateProblems(configPath, findings)...)
+		warnings = append(warnings, optionWarnings(configPath, unknownOptions)...)
+		if referencesErr != nil {
+			warnings = append(warnings, &SourceError{File: configPath, Message: referencesErr.Error()})
+		}
+		warnings = append(warnings, checkBrowsers(configPath, references)...)
+		return warnings.withSeverity(SeverityWarning)
+	}
+}
+
+// lintFindings returns the mistakes the config API finds in the config
+func (vm *VM) lintFindings() ([]configProblem, error) {
+	var findings []configProblem
+	err := vm.exportJSON("finickyConfigAPI.getLintFindings(finalConfig)", &findings)
+	return findings, err
+}
@@ -0,0 +1,48 @@
+package config
+
+import "os"
+
+// locateDeclarativePath returns the node at a path in a declarative config, as close as it can follow the path
+func locateDeclarativePath(root *declNode, path []interface{}) *declNode {
+	current := root
+	for _, segment := range path {
+		var next *declNode
+		switch key := segment.(type) {
+		case string:
+			if current.kind == declMap {
+				next = current.get(key)
+			}
+		case float64:
+			if current.kind == declList && int(key) >= 0 && int(key) < len(current.values) {
+				next = current.values[int(key)]
+			}
+		}
+		if next == nil {
+			break
+		}
+		current = next
+	}
+	return current
+}
+
+// locateConfigPath returns the 1-based line and column of the value at a path
+// into a declarative config, or zero for script configs, whose values can come
+// from anywhere the script computes them, so their problems are only located in
+// the file
+func locateConfigPath(file string, path []interface{}) (int, int) {
+	if !isDeclarativeConfig(file) {
+		return 0, 0
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return 0, 0
+	}
+
+	root, err := parseDeclarativeDocument(file, data)
+	if err != nil {
+		return 0, 0
+	}
+	node := locateDeclarativePath(root, path)
+	return node.line, node.column
+}
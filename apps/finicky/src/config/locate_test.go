@@ -0,0 +1,61 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocateConfigPath(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"finicky.yaml": `defaultBrowser: Safari
+options:
+  logRequests: "yes"
+handlers:
+  - match: example.com
+    browser: Firefox
+  - match:
+      - a.com
+      - b.com
+    browser: Unknown Browser
+`,
+		"finicky.ts": `export default {
+  defaultBrowser: "Safari",
+  options: { logRequests: "yes" },
+};
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		file   string
+		path   []interface{}
+		line   int
+		column int
+	}{
+		{"top level key", "finicky.yaml", []interface{}{"defaultBrowser"}, 1, 17},
+		{"nested key", "finicky.yaml", []interface{}{"options", "logRequests"}, 3, 16},
+		{"list element", "finicky.yaml", []interface{}{"handlers", 1.0, "browser"}, 10, 14},
+		{"nested list element", "finicky.yaml", []interface{}{"handlers", 1.0, "match", 1.0}, 9, 9},
+		// Paths that can't be followed are located as close as they get
+		{"missing element", "finicky.yaml", []interface{}{"handlers", 5.0}, 5, 3},
+		// Script configs are only located in the file
+		{"script config", "finicky.ts", []interface{}{"defaultBrowser"}, 0, 0},
+		{"missing file", "missing.yaml", []interface{}{"defaultBrowser"}, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, column := locateConfigPath(filepath.Join(dir, tt.file), tt.path)
+			if line != tt.line || column != tt.column {
+				t.Errorf("locateConfigPath() = %d:%d, want %d:%d", line, column, tt.line, tt.column)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package config
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Options are the options set in a config, parsed once when the config is loaded.
+// Adding an option only takes a field here and in the config API schema.
+type Options struct {
+	KeepRunning     bool `json:"keepRunning"`
+	HideIcon        bool `json:"hideIcon"`
+	LogRequests     bool `json:"logRequests"`
+	CheckForUpdates bool `json:"checkForUpdates"`
+	// UpdateChannel is "stable", or "beta" to also be offered pre-releases
+	UpdateChannel string `json:"updateChannel"`
+	// UpdateAPIHost overrides the host updates are checked with, e.g. for an internal mirror
+	UpdateAPIHost string `json:"updateApiHost"`
+	// URLShorteners are extra domains whose urls are resolved before matching
+	URLShorteners []string `json:"urlShorteners"`
+	// DisabledURLShorteners are domains whose urls are never resolved, even built-in ones
+	DisabledURLShorteners []string `json:"disabledUrlShorteners"`
+	// ShortenerRegistry is a file path or url of a JSON list of extra shortener domains
+	ShortenerRegistry string `json:"shortenerRegistry"`
+	// LogLevel is one of debug, info, warn or error, empty for the default
+	LogLevel string `json:"logLevel"`
+	// LogStdoutFormat, LogFileFormat and LogWindowFormat are "json" or "text", the
+	// format of the logs written to stdout, the log file and the window
+	LogStdoutFormat string `json:"logStdoutFormat"`
+	LogFileFormat   string `json:"logFileFormat"`
+	LogWindowFormat string `json:"logWindowFormat"`
+	// LogRedaction is "params" to mask sensitive query parameters, userinfo and fragments
+	// of logged urls, "hosts" to only log their hosts, or "off"
+	LogRedaction string `json:"logRedaction"`
+	// LogRedactedParams are query parameters masked in addition to the built-in ones, with "*" matching any characters
+	LogRedactedParams []string `json:"logRedactedParams"`
+	// LogFileMaxSizeMb is the size in megabytes a log file is rotated at, zero for no limit
+	LogFileMaxSizeMb int `json:"logFileMaxSizeMb"`
+	// LogFileMaxAgeHours is how long a log file is written to before it's rotated, zero for no limit
+	LogFileMaxAgeHours int `json:"logFileMaxAgeHours"`
+	// LogRetentionCount is the number of rotated log files kept, zero for no limit
+	LogRetentionCount int `json:"logRetentionCount"`
+	// LogRetentionSizeMb is the size in megabytes of all rotated log files kept, zero for no limit
+	LogRetentionSizeMb int `json:"logRetentionSizeMb"`
+	// ShortURLTimeoutMs limits how long resolving a short url can take, zero for no limit
+	ShortURLTimeoutMs int `json:"shortUrlTimeoutMs"`
+	// ShortURLMaxRedirects is the number of redirects followed when resolving a short url
+	ShortURLMaxRedirects int `json:"shortUrlMaxRedirects"`
+	// ShortURLMethods are the HTTP methods tried in order when resolving a short url
+	ShortURLMethods []string `json:"shortUrlMethods"`
+	// ShortURLUserAgent is the user agent sent to shorteners
+	ShortURLUserAgent string `json:"shortUrlUserAgent"`
+	// ShortURLHeaders are extra headers sent to shorteners by domain, "*" for all of them
+	ShortURLHeaders map[string]map[string]string `json:"shortUrlHeaders"`
+	// ShortURLCacheSize is the number of resolved short urls kept on disk, zero to disable the cache
+	ShortURLCacheSize int `json:"shortUrlCacheSize"`
+	// ShortURLResolution is "eager" to resolve short urls before the config runs, or
+	// "lazy" to only resolve them when the config calls finicky.resolveUrl
+	ShortURLResolution string `json:"shortUrlResolution"`
+	// SkipShortURLResolutionFor are opener names or bundle ids whose urls are never resolved over the network
+	SkipShortURLResolutionFor []string `json:"skipShortUrlResolutionFor"`
+	// StripTrackingParams removes tracking parameters from urls before the config sees them
+	StripTrackingParams bool `json:"stripTrackingParams"`
+	// TrackingParams are removed in addition to the built-in ones, with "*" at the end matching a prefix
+	TrackingParams []string `json:"trackingParams"`
+	// TrackingParamExceptions are tracking parameters kept by domain
+	TrackingParamExceptions map[string][]string `json:"trackingParamExceptions"`
+	// EvaluationTimeoutMs limits how long the config can take to pick a browser, zero for no limit
+	EvaluationTimeoutMs int `json:"evaluationTimeoutMs"`
+}
+
+// DefaultOptions returns the options used for anything a config doesn't set
+func DefaultOptions() Options {
+	return Options{
+		KeepRunning:           true,
+		CheckForUpdates:       true,
+		UpdateChannel:         "stable",
+		URLShorteners:         []string{},
+		DisabledURLShorteners: []string{},
+		LogStdoutFormat:       "json",
+		LogFileFormat:         "json",
+		LogWindowFormat:       "json",
+		LogRedaction:          "params",
+		LogRedactedParams:     []string{},
+		LogFileMaxSizeMb:      10,
+		LogFileMaxAgeHours:    24,
+		LogRetentionCount:     20,
+		LogRetentionSizeMb:    100,
+		ShortURLTimeoutMs:     750,
+		ShortURLMaxRedirects:  3,
+		ShortURLMethods:       []string{"HEAD", "GET"},
+		ShortURLUserAgent:     "Finicky/4.0",
+		ShortURLHeaders:       map[string]map[string]string{},
+		ShortURLCacheSize:     1000,
+
+		ShortURLResolution:        "eager",
+		SkipShortURLResolutionFor: []string{},
+
+		StripTrackingParams:     true,
+		TrackingParams:          []string{},
+		TrackingParamExceptions: map[string][]string{},
+	}
+}
+
+// optionNames are the keys of Options, in the order they're declared
+var optionNames = func() []string {
+	optionsType := reflect.TypeOf(Options{})
+	names := make([]string, optionsType.NumField())
+	for i := range names {
+		names[i] = strings.Split(optionsType.Field(i).Tag.Get("json"), ",")[0]
+	}
+	return names
+}()
+
+// parseOptions decodes the options of the loaded config on top of the defaults,
+// and returns the keys it doesn't know
+func (vm *VM) parseOptions() (Options, []string, error) {
+	options := DefaultOptions()
+
+	var raw json.RawMessage
+	if err := vm.exportJSON("finalConfig.options || {}", &raw); err != nil {
+		return options, nil, fmt.Errorf("failed to read options: %v", err)
+	}
+
+	if err := json.Unmarshal(raw, &options); err != nil {
+		return DefaultOptions(), nil, fmt.Errorf("invalid options: %v", err)
+	}
+
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &keys); err != nil {
+		return options, nil, nil
+	}
+
+	var unknown []string
+	for key := range keys {
+		if !slices.Contains(optionNames, key) {
+			unknown = append(unknown, key)
+		}
+	}
+	slices.Sort(unknown)
+
+	return options, unknown, nil
+}
+
+// LoadOptions loads the config at configPath, or the default one when it's empty,
+// and returns its options and path, for commands that run without the app
+func LoadOptions(embeddedFiles embed.FS, namespace string, configPath string) (Options, string, error) {
+	cfw := &ConfigFileWatcher{
+		customConfigPath: configPath,
+		namespace:        namespace,
+		watchedInputs:    make(map[string]bool),
+	}
+
+	resolvedPath, err := cfw.GetConfigPath(false)
+	if err != nil {
+		return DefaultOptions(), "", err
+	}
+
+	bundlePath, _, err := cfw.bundleConfigPath(resolvedPath)
+	if err != nil {
+		return DefaultOptions(), resolvedPath, err
+	}
+
+	vm, err := New(embeddedFiles, namespace, bundlePath)
+	if err != nil {
+		return DefaultOptions(), resolvedPath, err
+	}
+	return vm.Options(), resolvedPath, nil
+}
+
+// Options returns the options of the loaded config
+func (vm *VM) Options() Options {
+	return vm.options
+}
+
+// optionWarnings reports options the config sets that Finicky doesn't know
+func optionWarnings(configPath string, unknownOptions []string) SourceErrors {
+	var warnings SourceErrors
+	for _, key := range unknownOptions {
+		message := fmt.Sprintf("unknown option, expected one of %s", strings.Join(optionNames, ", "))
+		warnings = append(warnings, locateProblem(configPath, "", []interface{}{"options", key}, message))
+	}
+	return warnings
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dop251/goja"
+)
+
+func TestParseOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options string
+		want    func(options *Options)
+		unknown []string
+	}{
+		{
+			name:    "no options",
+			options: "undefined",
+			want:    func(options *Options) {},
+		},
+		{
+			name:    "every kind of option",
+			options: `{ hideIcon: true, urlShorteners: ["go.example.com"], logLevel: "debug", shortUrlTimeoutMs: 2000 }`,
+			want: func(options *Options) {
+				options.HideIcon = true
+				options.URLShorteners = []string{"go.example.com"}
+				options.LogLevel = "debug"
+				options.ShortURLTimeoutMs = 2000
+			},
+		},
+		{
+			name:    "unknown options",
+			options: `{ keepRunning: false, logRequest: true, colour: "red" }`,
+			want: func(options *Options) {
+				options.KeepRunning = false
+			},
+			unknown: []string{"colour", "logRequest"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vm := &VM{runtime: goja.New()}
+			if _, err := vm.runtime.RunString("var finalConfig = { options: " + tt.options + " }"); err != nil {
+				t.Fatal(err)
+			}
+
+			options, unknown, err := vm.parseOptions()
+			if err != nil {
+				t.Fatalf("parseOptions() error = %v", err)
+			}
+
+			want := DefaultOptions()
+			tt.want(&want)
+			if !reflect.DeepEqual(options, want) {
+				t.Errorf("parseOptions() = %+v, want %+v", options, want)
+			}
+			if !reflect.DeepEqual(unknown, tt.unknown) {
+				t.Errorf("parseOptions() unknown = %v, want %v", unknown, tt.unknown)
+			}
+		})
+	}
+}
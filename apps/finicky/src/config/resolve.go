@@ -0,0 +1,185 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// nodeBuiltinModules lists the Node.js core modules. The config runs in an
+// embedded JavaScript VM without any of them, so packages importing them can't work.
+var nodeBuiltinModules = []string{
+	"assert", "async_hooks", "buffer", "child_process", "cluster", "console", "constants",
+	"crypto", "dgram", "diagnostics_channel", "dns", "domain", "events", "fs", "http", "http2",
+	"https", "inspector", "module", "net", "os", "path", "perf_hooks", "process", "punycode",
+	"querystring", "readline", "repl", "stream", "string_decoder", "sys", "timers", "tls",
+	"trace_events", "tty", "url", "util", "v8", "vm", "wasi", "worker_threads", "zlib",
+}
+
+var nodeBuiltinFilter = fmt.Sprintf(`^(node:)?(%s)(/.*)?$`, strings.Join(nodeBuiltinModules, "|"))
+
+// configLoaders maps file extensions that may be imported from a config to esbuild loaders
+var configLoaders = map[string]api.Loader{
+	".ts.symlink": api.LoaderTS,
+	".js.symlink": api.LoaderJS,
+	".json":       api.LoaderJSON,
+	".txt":        api.LoaderText,
+}
+
+// nodeModulesPaths returns the node_modules directory next to the config file
+func nodeModulesPaths(configPath string) []string {
+	return []string{filepath.Join(filepath.Dir(configPath), "node_modules")}
+}
+
+// nodeBuiltinProbe marks resolve calls made by nodeBuiltinsPlugin itself
+type nodeBuiltinProbe struct{}
+
+// nodeBuiltinsPlugin fails the build with a readable error when the config or
+// one of its packages imports a Node.js built-in module. Bare names that are
+// installed in node_modules (e.g. the "events" polyfill) still resolve normally.
+func nodeBuiltinsPlugin() api.Plugin {
+	builtinPattern := regexp.MustCompile(nodeBuiltinFilter)
+
+	return api.Plugin{
+		Name: "finicky-node-builtins",
+		Setup: func(build api.PluginBuild) {
+			build.OnResolve(api.OnResolveOptions{Filter: nodeBuiltinFilter}, func(args api.OnResolveArgs) (api.OnResolveResult, error) {
+				if _, isProbe := args.PluginData.(nodeBuiltinProbe); isProbe {
+					return api.OnResolveResult{}, nil
+				}
+
+				if !strings.HasPrefix(args.Path, "node:") {
+					resolved := build.Resolve(args.Path, api.ResolveOptions{
+						Importer:   args.Importer,
+						Namespace:  args.Namespace,
+						ResolveDir: args.ResolveDir,
+						Kind:       args.Kind,
+						PluginData: nodeBuiltinProbe{},
+					})
+					if len(resolved.Errors) == 0 {
+						return api.OnResolveResult{Path: resolved.Path, Namespace: resolved.Namespace, External: resolved.External}, nil
+					}
+				}
+
+				matches := builtinPattern.FindStringSubmatch(args.Path)
+				module := args.Path
+				if len(matches) > 2 {
+					module = matches[2]
+				}
+
+				return api.OnResolveResult{}, fmt.Errorf(
+					"cannot import Node.js built-in module %q: it is not available in Finicky's config runtime, so packages used from a config must not depend on Node.js APIs",
+					module,
+				)
+			})
+		},
+	}
+}
+
+// buildSourceErrors converts esbuild messages into errors located in the source files
+func buildSourceErrors(messages []api.Message, workingDir string) SourceErrors {
+	var errs SourceErrors
+	for _, message := range messages {
+		sourceErr := &SourceError{Message: message.Text}
+		if message.Location != nil {
+			sourceErr.File = message.Location.File
+			if !filepath.IsAbs(sourceErr.File) {
+				sourceErr.File = filepath.Join(workingDir, sourceErr.File)
+			}
+			sourceErr.Line = message.Location.Line
+			sourceErr.Column = message.Location.Column + 1
+		}
+		errs = append(errs, sourceErr)
+	}
+	return errs
+}
+
+var babelPositionPattern = regexp.MustCompile(`\((\d+):(\d+)\)`)
+
+// babelSourceError locates a babel syntax error, which reports its position as "(line:column)"
+func babelSourceError(configPath string, err error) error {
+	message := strings.TrimPrefix(err.Error(), "SyntaxError: ")
+	message = strings.TrimPrefix(message, "unknown: ")
+
+	matches := babelPositionPattern.FindStringSubmatchIndex(message)
+	if matches == nil {
+		return SourceErrors{{File: configPath, Message: message}}
+	}
+
+	line, _ := strconv.Atoi(message[matches[2]:matches[3]])
+	column, _ := strconv.Atoi(message[matches[4]:matches[5]])
+	message = strings.TrimSpace(message[:matches[0]])
+
+	return SourceErrors{{File: configPath, Line: line, Column: column + 1, Message: message}}
+}
+
+// metafileInputs returns the absolute paths of the local files esbuild read
+// while bundling. Installed packages are represented by their package.json, so
+// installing another version of a package changes the inputs without every
+// file of every package being hashed and watched.
+func metafileInputs(metafile string, workingDir string) []string {
+	var meta struct {
+		Inputs map[string]json.RawMessage `json:"inputs"`
+	}
+	if err := json.Unmarshal([]byte(metafile), &meta); err != nil {
+		slog.Debug("Failed to parse esbuild metafile", "error", err)
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var inputs []string
+	for input := range meta.Inputs {
+		path := input
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workingDir, path)
+		}
+
+		if manifest, ok := packageManifest(path); ok {
+			path = manifest
+		}
+
+		// Skips virtual modules from plugin namespaces
+		if _, err := os.Stat(path); err != nil || seen[path] {
+			continue
+		}
+
+		seen[path] = true
+		inputs = append(inputs, path)
+	}
+
+	sort.Strings(inputs)
+	return inputs
+}
+
+// packageManifest returns the package.json of the installed package a file in
+// node_modules belongs to, including scoped and nested packages
+func packageManifest(path string) (string, bool) {
+	segments := strings.Split(path, string(filepath.Separator))
+	index := -1
+	for i, segment := range segments {
+		if segment == "node_modules" {
+			index = i
+		}
+	}
+	if index < 0 || index+1 >= len(segments) {
+		return "", false
+	}
+
+	end := index + 2
+	if strings.HasPrefix(segments[index+1], "@") {
+		end++
+	}
+	if end > len(segments) {
+		return "", false
+	}
+
+	return strings.Join(append(segments[:end:end], "package.json"), string(filepath.Separator)), true
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dop251/goja"
+)
+
+func TestMetafileInputs(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{
+		"finicky.js",
+		"lib/rules.js",
+		"node_modules/left-pad/package.json",
+		"node_modules/left-pad/index.js",
+		"node_modules/left-pad/lib/pad.js",
+		"node_modules/@scope/tools/package.json",
+		"node_modules/@scope/tools/dist/index.js",
+		"node_modules/@scope/tools/node_modules/nested/package.json",
+		"node_modules/@scope/tools/node_modules/nested/index.js",
+	}
+	inputs := map[string]interface{}{}
+	for _, file := range files {
+		path := filepath.Join(dir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if filepath.Base(file) != "package.json" {
+			inputs[file] = struct{}{}
+		}
+	}
+	// Virtual modules of plugins aren't files
+	inputs["node-builtin:fs"] = struct{}{}
+
+	metafile, err := json.Marshal(map[string]interface{}{"inputs": inputs})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var expected []string
+	for _, file := range []string{
+		"finicky.js",
+		"lib/rules.js",
+		"node_modules/@scope/tools/node_modules/nested/package.json",
+		"node_modules/@scope/tools/package.json",
+		"node_modules/left-pad/package.json",
+	} {
+		expected = append(expected, filepath.Join(dir, file))
+	}
+
+	if got := metafileInputs(string(metafile), dir); !slices.Equal(got, expected) {
+		t.Errorf("metafileInputs() = %q, want %q", got, expected)
+	}
+}
+
+func TestBundleImports(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"browsers.json":                      `{"work": "Google Chrome"}`,
+		"domains.txt":                        "example.com\nexample.org\n",
+		"node_modules/left-pad/package.json": `{"name": "left-pad", "main": "lib/index.js"}`,
+		"node_modules/left-pad/lib/index.js": `module.exports = function (value, length) { return value.padStart(length, "-"); };`,
+		// Installed packages with the name of a Node.js module are used instead of it
+		"node_modules/events/package.json": `{"name": "events", "main": "events.js"}`,
+		"node_modules/events/events.js":    `exports.name = "events polyfill";`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		config   string
+		expected string
+		inputs   []string
+		err      string
+	}{
+		{
+			name: "package, json and text files",
+			config: `import leftPad from "left-pad";
+import browsers from "./browsers.json";
+import domains from "./domains.txt";
+export default [leftPad("a", 3), browsers.work, domains.trim().split("\n")];
+`,
+			expected: `["--a","Google Chrome",["example.com","example.org"]]`,
+			inputs:   []string{"browsers.json", "domains.txt", "finicky.ts", "node_modules/left-pad/package.json"},
+		},
+		{
+			name:     "installed node module name",
+			config:   `import { name } from "events"; export default name;`,
+			expected: `"events polyfill"`,
+			inputs:   []string{"finicky.ts", "node_modules/events/package.json"},
+		},
+		{
+			name:   "node built-in",
+			config: `import { readFileSync } from "fs"; export default readFileSync;`,
+			err:    `cannot import Node.js built-in module "fs"`,
+		},
+		{
+			name:   "node built-in with the node: prefix",
+			config: `import { name } from "node:events"; export default name;`,
+			err:    `cannot import Node.js built-in module "events"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configPath := filepath.Join(dir, "finicky.ts")
+			if err := os.WriteFile(configPath, []byte(tt.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := (&ConfigFileWatcher{}).bundle(configPath, "finickyConfig")
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("bundle() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("bundle() error = %v", err)
+			}
+
+			runtime := goja.New()
+			if _, err := runtime.RunString(string(result.contents)); err != nil {
+				t.Fatalf("RunString() error = %v", err)
+			}
+			got, err := runtime.RunString("JSON.stringify(finickyConfig.default)")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != tt.expected {
+				t.Errorf("default export = %s, want %s", got, tt.expected)
+			}
+
+			var expectedInputs []string
+			for _, input := range tt.inputs {
+				expectedInputs = append(expectedInputs, filepath.Join(dir, input))
+			}
+			if !slices.Equal(result.inputs, expectedInputs) {
+				t.Errorf("inputs = %q, want %q", result.inputs, expectedInputs)
+			}
+		})
+	}
+}
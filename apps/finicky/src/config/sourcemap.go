@@ -0,0 +1,216 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// apiScriptName is the file name the config API runs under, so its frames can be told apart from config frames
+const apiScriptName = "finickyConfigAPI.js"
+
+// excerptContext is the number of lines shown around the line an error was thrown from
+const excerptContext = 2
+
+// absoluteSourceMap rewrites the sources of an esbuild source map, which are
+// relative to dir, into absolute paths so stack traces point at the original files
+func absoluteSourceMap(sourceMap []byte, dir string) []byte {
+	var parsed map[string]interface{}
+	if err := json.Unmarshal(sourceMap, &parsed); err != nil {
+		return sourceMap
+	}
+
+	sources, ok := parsed["sources"].([]interface{})
+	if !ok {
+		return sourceMap
+	}
+
+	for i, source := range sources {
+		if path, ok := source.(string); ok && !filepath.IsAbs(path) {
+			sources[i] = filepath.Join(dir, path)
+		}
+	}
+
+	result, err := json.Marshal(parsed)
+	if err != nil {
+		return sourceMap
+	}
+	return result
+}
+
+// withInlineSourceMap appends a source map to a bundle as a data URL, which goja
+// picks up to report positions in the original files
+func withInlineSourceMap(contents []byte, sourceMap []byte) []byte {
+	if len(sourceMap) == 0 {
+		return contents
+	}
+
+	var builder strings.Builder
+	builder.Write(contents)
+	builder.WriteString("\n//# sourceMappingURL=data:application/json;base64,")
+	builder.WriteString(base64.StdEncoding.EncodeToString(sourceMap))
+	builder.WriteString("\n")
+	return []byte(builder.String())
+}
+
+// sourceMapSection places the source map of one part of a combined bundle at the line it starts on
+type sourceMapSection struct {
+	Offset struct {
+		Line   int `json:"line"`
+		Column int `json:"column"`
+	} `json:"offset"`
+	Map json.RawMessage `json:"map"`
+}
+
+// indexSourceMap combines the source maps of the parts of a bundle into an index source map
+func indexSourceMap(sections []sourceMapSection) []byte {
+	if len(sections) == 0 {
+		return nil
+	}
+
+	result, err := json.Marshal(map[string]interface{}{
+		"version":  3,
+		"sections": sections,
+	})
+	if err != nil {
+		return nil
+	}
+	return result
+}
+
+// StackFrame is a frame of a stack trace thrown by config code
+type StackFrame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+}
+
+func (f StackFrame) String() string {
+	location := fmt.Sprintf("%s:%d:%d", f.File, f.Line, f.Column)
+	if f.Function == "" {
+		return location
+	}
+	return fmt.Sprintf("%s (%s)", f.Function, location)
+}
+
+// ConfigError is an error thrown by config code while evaluating a url, with
+// its stack trace pointing at the original config files
+type ConfigError struct {
+	Message string
+	Frames  []StackFrame
+}
+
+// goja formats frames as "at function (file:line:column(pc))", with the function and parentheses optional
+var stackFramePattern = regexp.MustCompile(`^\s*at (?:(.+?) \()?(.+):(\d+):(\d+)\(\d+\)\)?$`)
+
+// NewConfigError creates an error from a message and a goja stack trace, keeping only the frames in config files
+func NewConfigError(message string, stack string) *ConfigError {
+	configErr := &ConfigError{Message: message}
+
+	for _, line := range strings.Split(stack, "\n") {
+		matches := stackFramePattern.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+
+		file := matches[2]
+		if file == apiScriptName || file == "<eval>" || !filepath.IsAbs(file) {
+			continue
+		}
+
+		lineNumber, _ := strconv.Atoi(matches[3])
+		column, _ := strconv.Atoi(matches[4])
+		// goja passes on the 0-based columns of source maps, only positions in the
+		// bundle itself are 1-based
+		if !strings.HasPrefix(filepath.Base(file), bundleFilePrefix) {
+			column++
+		}
+		configErr.Frames = append(configErr.Frames, StackFrame{
+			Function: matches[1],
+			File:     file,
+			Line:     lineNumber,
+			Column:   column,
+		})
+	}
+
+	return configErr
+}
+
+// ConfigErrorFromException creates an error from an exception thrown into Go by
+// the VM, using the stack of the config error it wraps when there is one
+func ConfigErrorFromException(err error) error {
+	exception, ok := err.(*goja.Exception)
+	if !ok {
+		return err
+	}
+
+	stack := exception.String()
+	if object, ok := exception.Value().(*goja.Object); ok {
+		if configStack := object.Get("configStack"); configStack != nil && !goja.IsUndefined(configStack) {
+			stack = configStack.String()
+		}
+	}
+
+	message := exception.Value().String()
+	configErr := NewConfigError(message, stack)
+	if len(configErr.Frames) == 0 {
+		return err
+	}
+	return configErr
+}
+
+func (e *ConfigError) Error() string {
+	var builder strings.Builder
+	builder.WriteString(e.Message)
+
+	for i, frame := range e.Frames {
+		builder.WriteString("\n    at ")
+		builder.WriteString(frame.String())
+		if i == 0 {
+			builder.WriteString(codeExcerpt(frame.File, frame.Line, frame.Column))
+		}
+	}
+
+	return builder.String()
+}
+
+// codeExcerpt returns the lines around a position in a file with a marker under the column
+func codeExcerpt(file string, line int, column int) string {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+
+	lines := strings.Split(string(content), "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+
+	first := max(line-excerptContext, 1)
+	last := min(line+excerptContext, len(lines))
+	width := len(strconv.Itoa(last))
+
+	var builder strings.Builder
+	builder.WriteString("\n")
+	for number := first; number <= last; number++ {
+		marker := " "
+		if number == line {
+			marker = ">"
+		}
+		builder.WriteString(fmt.Sprintf("\n  %s %*d | %s", marker, width, number, strings.TrimRight(lines[number-1], "\r")))
+		if number == line && column > 0 {
+			builder.WriteString(fmt.Sprintf("\n    %s | %s^", strings.Repeat(" ", width), strings.Repeat(" ", column-1)))
+		}
+	}
+	builder.WriteString("\n")
+
+	return builder.String()
+}
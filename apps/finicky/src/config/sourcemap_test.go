@@ -0,0 +1,203 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dop251/goja"
+)
+
+func TestConfigErrorSourceMap(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"10-work.ts": `export default {
+  handlers: [
+    {
+      match: (url: string) => {
+        throw new Error("work failed");
+      },
+      browser: "Safari",
+    },
+  ],
+};
+`,
+		"20-home.ts": `import { check } from "./lib/check";
+
+const unused: number = 1;
+
+export default {
+  rewrite: [
+    {
+      match: () => true,
+      url: (url: string) => check(url),
+    },
+  ],
+};
+`,
+		"lib/check.ts": `export function check(url: string): string {
+  if (url) {
+    throw new TypeError("home failed");
+  }
+  return url;
+}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfw := &ConfigFileWatcher{namespace: "finickyConfig"}
+	var results []*bundleResult
+	names := []string{"10-work.ts", "20-home.ts"}
+	for _, name := range names {
+		result, err := cfw.bundle(filepath.Join(dir, name), fragmentGlobalName)
+		if err != nil {
+			t.Fatalf("bundle(%s) error = %v", name, err)
+		}
+		results = append(results, result)
+	}
+
+	bundlePath := filepath.Join(dir, "bundle.js")
+	runtime := goja.New()
+	if _, err := runtime.RunString("var finickyConfigAPI = { mergeConfigFragments: function (fragments) { return fragments; } };"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runtime.RunScript(bundlePath, string(mergeFragmentBundles(cfw.namespace, names, results))); err != nil {
+		t.Fatalf("RunScript() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		script   string
+		message  string
+		expected []StackFrame
+	}{
+		{
+			name:    "first fragment",
+			script:  "finickyConfig.default[0].config.default.handlers[0].match('https://example.com')",
+			message: "Error: work failed",
+			expected: []StackFrame{
+				{Function: "match", File: filepath.Join(dir, "10-work.ts"), Line: 5, Column: 15},
+			},
+		},
+		{
+			name:    "module imported by the second fragment",
+			script:  "finickyConfig.default[1].config.default.rewrite[0].url('https://example.com')",
+			message: "TypeError: home failed",
+			expected: []StackFrame{
+				{Function: "check", File: filepath.Join(dir, "lib/check.ts"), Line: 3, Column: 11},
+				{Function: "url", File: filepath.Join(dir, "20-home.ts"), Line: 9, Column: 35},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runtime.RunString(tt.script)
+			var configErr *ConfigError
+			if !errors.As(ConfigErrorFromException(err), &configErr) {
+				t.Fatalf("ConfigErrorFromException(%v) is not a config error", err)
+			}
+			if configErr.Message != tt.message {
+				t.Errorf("message = %q, want %q", configErr.Message, tt.message)
+			}
+			if len(configErr.Frames) != len(tt.expected) {
+				t.Fatalf("frames = %v, want %v", configErr.Frames, tt.expected)
+			}
+			for i, frame := range configErr.Frames {
+				if frame != tt.expected[i] {
+					t.Errorf("frame %d = %v, want %v", i, frame, tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCodeExcerpt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "finicky.js")
+	content := "const a = 1;\nconst b = 2;\r\nthrow new Error(a + b);\nconst c = 3;\nconst d = 4;\nconst e = 5;\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		line     int
+		column   int
+		expected string
+	}{
+		{
+			name:   "marks the column",
+			line:   3,
+			column: 7,
+			expected: `
+    1 | const a = 1;
+    2 | const b = 2;
+  > 3 | throw new Error(a + b);
+      |       ^
+    4 | const c = 3;
+    5 | const d = 4;
+`,
+		},
+		{
+			name: "first line without a column",
+			line: 1,
+			expected: `
+  > 1 | const a = 1;
+    2 | const b = 2;
+    3 | throw new Error(a + b);
+`,
+		},
+		{
+			name: "line outside of the file",
+			line: 20,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := codeExcerpt(path, tt.line, tt.column)
+			if tt.expected != "" {
+				tt.expected = "\n" + tt.expected
+			}
+			if got != tt.expected {
+				t.Errorf("codeExcerpt() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNewConfigError(t *testing.T) {
+	stack := strings.Join([]string{
+		"Error: failed",
+		"    at check (/config/lib/check.js:3:10(12))",
+		"    at /config/finicky.js:9:36(4)",
+		"    at run (/cache/finicky_bundle_0123abcd.js:40:5(2))",
+		"    at validateConfig (finickyConfigAPI.js:1:200(30))",
+		"    at <eval>:1:1(3)",
+	}, "\n")
+
+	expected := []StackFrame{
+		{Function: "check", File: "/config/lib/check.js", Line: 3, Column: 11},
+		{File: "/config/finicky.js", Line: 9, Column: 37},
+		{Function: "run", File: "/cache/finicky_bundle_0123abcd.js", Line: 40, Column: 5},
+	}
+	configErr := NewConfigError("Error: failed", stack)
+	if len(configErr.Frames) != len(expected) {
+		t.Fatalf("frames = %v, want %v", configErr.Frames, expected)
+	}
+	for i, frame := range configErr.Frames {
+		if frame != expected[i] {
+			t.Errorf("frame %d = %v, want %v", i, frame, expected[i])
+		}
+	}
+}
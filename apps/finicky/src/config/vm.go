@@ -2,6 +2,8 @@ package config
 
 import (
 	"embed"
+	"encoding/json"
+	"finicky/schedule"
 	"finicky/util"
 	"fmt"
 	"log/slog"
@@ -11,8 +13,12 @@ import (
 )
 
 type VM struct {
-	runtime   *goja.Runtime
-	namespace string
+	runtime        *goja.Runtime
+	namespace      string
+	clock          schedule.Clock
+	options        Options
+	unknownOptions []string
+	urlResolver    func(url string) string
 }
 
 // ConfigState represents the current state of the configuration
@@ -26,6 +32,7 @@ func New(embeddedFiles embed.FS, namespace string, bundlePath string) (*VM, erro
 	vm := &VM{
 		runtime:   goja.New(),
 		namespace: namespace,
+		clock:     schedule.SystemClock,
 	}
 
 	err := vm.setup(embeddedFiles, bundlePath)
@@ -37,6 +44,28 @@ func New(embeddedFiles embed.FS, namespace string, bundlePath string) (*VM, erro
 }
 
 func (vm *VM) setup(embeddedFiles embed.FS, bundlePath string) error {
+	if err := vm.load(embeddedFiles, bundlePath); err != nil {
+		return err
+	}
+
+	validConfig, err := vm.runtime.RunString("finickyConfigAPI.validateConfig(finalConfig)")
+	if err != nil {
+		return fmt.Errorf("failed to validate config: %v", err)
+	}
+	if !validConfig.ToBoolean() {
+		return fmt.Errorf("configuration is invalid")
+	}
+
+	vm.options, vm.unknownOptions, err = vm.parseOptions()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// load runs the config API and the bundled config, leaving the config in finalConfig without validating it
+func (vm *VM) load(embeddedFiles embed.FS, bundlePath string) error {
 	apiContent, err := embeddedFiles.ReadFile("assets/finickyConfigAPI.js")
 	if err != nil {
 		return fmt.Errorf("failed to read bundled file: %v", err)
@@ -54,7 +83,7 @@ func (vm *VM) setup(embeddedFiles embed.FS, bundlePath string) error {
 	vm.runtime.Set("console", GetConsoleMap())
 
 	slog.Debug("Evaluating API script...")
-	if _, err = vm.runtime.RunString(string(apiContent)); err != nil {
+	if _, err = vm.runtime.RunScript(apiScriptName, string(apiContent)); err != nil {
 		return fmt.Errorf("failed to run api script: %v", err)
 	}
 	slog.Debug("Done evaluating API script")
@@ -70,12 +99,15 @@ func (vm *VM) setup(embeddedFiles embed.FS, bundlePath string) error {
 	finicky["getSystemInfo"] = util.GetSystemInfo
 	finicky["getPowerInfo"] = util.GetPowerInfo
 	finicky["isAppRunning"] = util.IsAppRunning
+	finicky["now"] = vm.now
+	finicky["isWithinSchedule"] = vm.isWithinSchedule
+	finicky["resolveUrl"] = vm.resolveURL
 
 	vm.runtime.Set("finicky", finicky)
 
 	if content != nil {
-		if _, err = vm.runtime.RunString(string(content)); err != nil {
-			return fmt.Errorf("error while running config script: %v", err)
+		if _, err = vm.runtime.RunScript(bundlePath, string(content)); err != nil {
+			return fmt.Errorf("error while running config script: %v", ConfigErrorFromException(err))
 		}
 	} else {
 		vm.runtime.Set(vm.namespace, map[string]interface{}{})
@@ -89,14 +121,6 @@ func (vm *VM) setup(embeddedFiles embed.FS, bundlePath string) error {
 
 	vm.runtime.Set("finalConfig", finalConfig)
 
-	validConfig, err := vm.runtime.RunString("finickyConfigAPI.validateConfig(finalConfig)")
-	if err != nil {
-		return fmt.Errorf("failed to validate config: %v", err)
-	}
-	if !validConfig.ToBoolean() {
-		return fmt.Errorf("configuration is invalid")
-	}
-
 	return nil
 }
 
@@ -126,3 +150,72 @@ func (vm *VM) GetConfigState() *ConfigState {
 func (vm *VM) Runtime() *goja.Runtime {
 	return vm.runtime
 }
+
+// SetClock replaces the clock used by time based helpers, e.g. to simulate a
+// different time when testing URLs. Passing nil restores the system clock.
+func (vm *VM) SetClock(clock schedule.Clock) {
+	if clock == nil {
+		clock = schedule.SystemClock
+	}
+	vm.clock = clock
+}
+
+// SetURLResolver replaces how finicky.resolveUrl resolves urls, e.g. to skip the
+// network for some openers. Passing nil makes it return urls unchanged.
+func (vm *VM) SetURLResolver(resolver func(url string) string) {
+	vm.urlResolver = resolver
+}
+
+// resolveURL implements finicky.resolveUrl(url) and returns where a short url leads
+func (vm *VM) resolveURL(url string) string {
+	if vm.urlResolver == nil {
+		return url
+	}
+	return vm.urlResolver(url)
+}
+
+// now implements finicky.now([tz]) and returns the current time broken down
+// into calendar fields for the given time zone
+func (vm *VM) now(call goja.FunctionCall) goja.Value {
+	tz := ""
+	if arg := call.Argument(0); !goja.IsUndefined(arg) && !goja.IsNull(arg) {
+		tz = arg.String()
+	}
+
+	moment, err := schedule.Now(vm.clock, tz)
+	if err != nil {
+		panic(vm.runtime.NewGoError(err))
+	}
+
+	date, err := vm.runtime.New(vm.runtime.Get("Date"), vm.runtime.ToValue(moment.Time.UnixMilli()))
+	if err != nil {
+		panic(vm.runtime.NewGoError(err))
+	}
+
+	return vm.runtime.ToValue(map[string]interface{}{
+		"date":     date,
+		"year":     moment.Year,
+		"month":    moment.Month,
+		"day":      moment.Day,
+		"weekday":  moment.Weekday,
+		"hour":     moment.Hour,
+		"minute":   moment.Minute,
+		"second":   moment.Second,
+		"timezone": moment.Timezone,
+	})
+}
+
+// isWithinSchedule implements finicky.isWithinSchedule({ days, from, to, tz })
+func (vm *VM) isWithinSchedule(scheduleValue map[string]interface{}) (bool, error) {
+	scheduleBytes, err := json.Marshal(scheduleValue)
+	if err != nil {
+		return false, fmt.Errorf("invalid schedule: %v", err)
+	}
+
+	var s schedule.Schedule
+	if err := json.Unmarshal(scheduleBytes, &s); err != nil {
+		return false, fmt.Errorf("invalid schedule: %v", err)
+	}
+
+	return schedule.IsWithin(vm.clock, s)
+}
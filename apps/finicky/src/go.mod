@@ -9,6 +9,8 @@ require (
 	github.com/evanw/esbuild v0.24.2
 	github.com/fsnotify/fsnotify v1.8.0
 	github.com/jvatic/goja-babel v0.0.0-20250308121736-c08d87dbdc10
+	github.com/pelletier/go-toml/v2 v2.2.3
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
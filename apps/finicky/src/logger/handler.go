@@ -0,0 +1,178 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+// Formats of log output
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// ParseLevel parses one of debug, info, warn or error
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return level, fmt.Errorf("invalid log level %q, expected one of debug, info, warn or error", name)
+	}
+	return level, nil
+}
+
+// newSinkHandler creates a handler writing records to writer in a format
+func newSinkHandler(writer io.Writer, format string, level slog.Leveler) slog.Handler {
+	options := &slog.HandlerOptions{
+		Level:     level,
+		AddSource: false,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			// Format time as ISO string with microseconds
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{
+					Key:   slog.TimeKey,
+					Value: slog.StringValue(a.Value.Time().Format("2006-01-02 15:04:05.000000")),
+				}
+			}
+			return a
+		},
+	}
+
+	if format == FormatText {
+		return slog.NewTextHandler(writer, options)
+	}
+	return slog.NewJSONHandler(writer, options)
+}
+
+// groupOrAttrs is a group or attributes added to a handler with WithGroup or WithAttrs
+type groupOrAttrs struct {
+	group string
+	attrs []slog.Attr
+}
+
+// withChain adds groups and attributes to a handler in the order they were added
+func withChain(handler slog.Handler, chain []groupOrAttrs) slog.Handler {
+	for _, goa := range chain {
+		if goa.group != "" {
+			handler = handler.WithGroup(goa.group)
+		} else {
+			handler = handler.WithAttrs(goa.attrs)
+		}
+	}
+	return handler
+}
+
+// fanoutHandler sends records at or above a level to every sink with their urls
+// redacted, and keeps them in a buffer to replay to sinks added later
+type fanoutHandler struct {
+	level    slog.Leveler
+	sinks    []slog.Handler
+	buffer   *recordBuffer
+	redactor *Redactor
+	// chain are the groups and attributes of the handler, already redacted and added to the sinks
+	chain []groupOrAttrs
+}
+
+func (h *fanoutHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *fanoutHandler) Handle(ctx context.Context, record slog.Record) error {
+	if h.buffer != nil {
+		h.buffer.add(record, h.chain)
+	}
+
+	record = h.redactor.redactRecord(record)
+	var errs []error
+	for _, sink := range h.sinks {
+		if sink.Enabled(ctx, record.Level) {
+			errs = append(errs, sink.Handle(ctx, record.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h *fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return h.with(groupOrAttrs{attrs: attrs})
+}
+
+func (h *fanoutHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return h.with(groupOrAttrs{group: name})
+}
+
+func (h *fanoutHandler) with(goa groupOrAttrs) *fanoutHandler {
+	goa.attrs = h.redactor.redactAttrs(goa.attrs)
+
+	sinks := make([]slog.Handler, len(h.sinks))
+	for i, sink := range h.sinks {
+		sinks[i] = withChain(sink, []groupOrAttrs{goa})
+	}
+	return &fanoutHandler{
+		level:    h.level,
+		sinks:    sinks,
+		buffer:   h.buffer,
+		redactor: h.redactor,
+		chain:    append(slices.Clip(h.chain), goa),
+	}
+}
+
+// recordBuffer keeps the latest records in a ring of fixed size, so a long running
+// app only holds the most recent logs in memory
+type recordBuffer struct {
+	mu      sync.Mutex
+	records []bufferedRecord
+	// next is where the next record goes, overwriting the oldest once the ring is full
+	next int
+	full bool
+}
+
+type bufferedRecord struct {
+	record slog.Record
+	chain  []groupOrAttrs
+}
+
+func newRecordBuffer(size int) *recordBuffer {
+	return &recordBuffer{records: make([]bufferedRecord, size)}
+}
+
+func (b *recordBuffer) add(record slog.Record, chain []groupOrAttrs) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.records) == 0 {
+		return
+	}
+	b.records[b.next] = bufferedRecord{record: record.Clone(), chain: chain}
+	b.next = (b.next + 1) % len(b.records)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// replay sends the buffered records to a handler, from oldest to newest
+func (b *recordBuffer) replay(ctx context.Context, handler slog.Handler, redactor *Redactor) error {
+	b.mu.Lock()
+	records := slices.Clone(b.records[:b.next])
+	if b.full {
+		records = append(slices.Clone(b.records[b.next:]), records...)
+	}
+	b.mu.Unlock()
+
+	var errs []error
+	for _, buffered := range records {
+		if handler.Enabled(ctx, buffered.record.Level) {
+			errs = append(errs, withChain(handler, buffered.chain).Handle(ctx, redactor.redactRecord(buffered.record.Clone())))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,124 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestFanoutHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		level      slog.Level
+		bufferSize int
+		log        func(logger *slog.Logger)
+		// want are the lines of the json sink, the text sink and the sink replaying the buffer
+		wantJSON   []string
+		wantText   []string
+		wantReplay []string
+	}{
+		{
+			name:       "writes every sink in its format",
+			level:      slog.LevelDebug,
+			bufferSize: 10,
+			log: func(logger *slog.Logger) {
+				logger.Info("URL received", "url", "https://example.com")
+			},
+			wantJSON:   []string{`{"time":"T","level":"INFO","msg":"URL received","url":"https://example.com"}`},
+			wantText:   []string{`time="T" level=INFO msg="URL received" url=https://example.com`},
+			wantReplay: []string{`time="T" level=INFO msg="URL received" url=https://example.com`},
+		},
+		{
+			name:       "drops records below the level",
+			level:      slog.LevelInfo,
+			bufferSize: 10,
+			log: func(logger *slog.Logger) {
+				logger.Debug("hidden")
+				logger.Warn("shown")
+			},
+			wantJSON:   []string{`{"time":"T","level":"WARN","msg":"shown"}`},
+			wantText:   []string{`time="T" level=WARN msg=shown`},
+			wantReplay: []string{`time="T" level=WARN msg=shown`},
+		},
+		{
+			name:       "buffer keeps the latest records",
+			level:      slog.LevelDebug,
+			bufferSize: 2,
+			log: func(logger *slog.Logger) {
+				logger.Info("one")
+				logger.Info("two")
+				logger.Info("three")
+			},
+			wantJSON: []string{
+				`{"time":"T","level":"INFO","msg":"one"}`,
+				`{"time":"T","level":"INFO","msg":"two"}`,
+				`{"time":"T","level":"INFO","msg":"three"}`,
+			},
+			wantText: []string{
+				`time="T" level=INFO msg=one`,
+				`time="T" level=INFO msg=two`,
+				`time="T" level=INFO msg=three`,
+			},
+			wantReplay: []string{
+				`time="T" level=INFO msg=two`,
+				`time="T" level=INFO msg=three`,
+			},
+		},
+		{
+			name:       "groups and attributes",
+			level:      slog.LevelDebug,
+			bufferSize: 10,
+			log: func(logger *slog.Logger) {
+				logger.With("component", "resolver").WithGroup("request").Info("done", "status", 200)
+			},
+			wantJSON:   []string{`{"time":"T","level":"INFO","msg":"done","component":"resolver","request":{"status":200}}`},
+			wantText:   []string{`time="T" level=INFO msg=done component=resolver request.status=200`},
+			wantReplay: []string{`time="T" level=INFO msg=done component=resolver request.status=200`},
+		},
+	}
+
+	timestamp := regexp.MustCompile(`\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\.\d{6}`)
+	lines := func(output *bytes.Buffer) []string {
+		return strings.Split(strings.TrimSpace(timestamp.ReplaceAllString(output.String(), "T")), "\n")
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level := new(slog.LevelVar)
+			level.Set(tt.level)
+
+			var jsonOutput, textOutput, replayOutput bytes.Buffer
+			handler := &fanoutHandler{
+				level: level,
+				sinks: []slog.Handler{
+					newSinkHandler(&jsonOutput, FormatJSON, level),
+					newSinkHandler(&textOutput, FormatText, level),
+				},
+				buffer: newRecordBuffer(tt.bufferSize),
+			}
+
+			tt.log(slog.New(handler))
+			if err := handler.buffer.replay(context.Background(), newSinkHandler(&replayOutput, FormatText, level), nil); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, sink := range []struct {
+				name   string
+				output *bytes.Buffer
+				want   []string
+			}{
+				{"json", &jsonOutput, tt.wantJSON},
+				{"text", &textOutput, tt.wantText},
+				{"replay", &replayOutput, tt.wantReplay},
+			} {
+				got := lines(sink.output)
+				if strings.Join(got, "\n") != strings.Join(sink.want, "\n") {
+					t.Errorf("%s sink = %q, want %q", sink.name, got, sink.want)
+				}
+			}
+		})
+	}
+}
@@ -1,19 +1,43 @@
 package logger
 
 import (
-	"bytes"
+	"context"
+	"errors"
 	"finicky/util"
 	"finicky/window"
 	"fmt"
-	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"time"
 )
 
-var memLog *bytes.Buffer
-var file *os.File
+// bufferSize is how many records are kept in memory, to be replayed when file logging starts
+const bufferSize = 1000
+
+// Settings control what is logged and how each sink formats it
+type Settings struct {
+	// Level is debug, info, warn or error, empty for debug
+	Level string
+	// The formats are FormatJSON or FormatText, empty for FormatJSON
+	StdoutFormat string
+	FileFormat   string
+	WindowFormat string
+	// Redaction is RedactParams, RedactHostsOnly or RedactOff, empty for RedactParams
+	Redaction string
+	// RedactedParams are query parameters masked in addition to the built-in ones
+	RedactedParams []string
+}
+
+var (
+	level    = new(slog.LevelVar)
+	settings Settings
+	buffer   = newRecordBuffer(bufferSize)
+	// redactor masks urls in every sink, sensitive parameters are masked until a config says otherwise
+	redactor, _ = NewRedactor(RedactParams, nil)
+	// logFile is written to for the whole life of the process, across config reloads
+	logFile *rotatingFile
+)
 
 // windowWriter implements io.Writer to send logs to the window
 type windowWriter struct{}
@@ -29,36 +53,56 @@ func (w *windowWriter) Write(p []byte) (n int, err error) {
 	return len(p), nil
 }
 
-// createHandler creates a slog handler with the given writer
-func createHandler(writer io.Writer) slog.Handler {
-	return slog.NewJSONHandler(writer, &slog.HandlerOptions{
-		Level:     slog.LevelDebug,
-		AddSource: false,
-		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
-			// Format time as ISO string with microseconds
-			if a.Key == slog.TimeKey {
-				return slog.Attr{
-					Key:   slog.TimeKey,
-					Value: slog.StringValue(a.Value.Time().Format("2006-01-02 15:04:05.000000")),
-				}
-			}
-			return a
-		},
-	})
+// installHandler sets the default logger to one writing to every sink
+func installHandler() {
+	sinks := []slog.Handler{
+		newSinkHandler(os.Stdout, settings.StdoutFormat, level),
+		newSinkHandler(&windowWriter{}, settings.WindowFormat, level),
+	}
+	if logFile != nil {
+		sinks = append(sinks, newSinkHandler(logFile, settings.FileFormat, level))
+	}
+
+	slog.SetDefault(slog.New(&fanoutHandler{level: level, sinks: sinks, buffer: buffer, redactor: redactor}))
 }
 
 // Setup initializes the logger with basic configuration
 func Setup() {
+	level.Set(slog.LevelDebug)
 	// Start with in-memory logging
-	memLog = &bytes.Buffer{}
-	multiWriter := io.MultiWriter(memLog, os.Stdout, &windowWriter{})
+	installHandler()
+}
+
+// Configure applies the log level, formats and redaction of the config. An invalid
+// level keeps the current one, and an invalid redaction masks sensitive parameters.
+func Configure(newSettings Settings) error {
+	var errs []error
+
+	newLevel := slog.LevelDebug
+	if newSettings.Level != "" {
+		var err error
+		if newLevel, err = ParseLevel(newSettings.Level); err != nil {
+			errs = append(errs, err)
+			newLevel = level.Level()
+		}
+	}
+	level.Set(newLevel)
+
+	newRedactor, err := NewRedactor(newSettings.Redaction, newSettings.RedactedParams)
+	if err != nil {
+		errs = append(errs, err)
+		newRedactor, _ = NewRedactor(RedactParams, nil)
+	}
 
-	// Set the default logger
-	slog.SetDefault(slog.New(createHandler(multiWriter)))
+	settings = newSettings
+	redactor = newRedactor
+	installHandler()
+	return errors.Join(errs...)
 }
 
-// SetupFile configures file logging if enabled
-func SetupFile(shouldLog bool) error {
+// SetupFile configures file logging if enabled. The first call opens the log
+// file of the process, later ones only update its rotation limits.
+func SetupFile(shouldLog bool, limits RotationLimits) error {
 	slog.Debug("Setting up file logging", "shouldLog", shouldLog)
 	if shouldLog {
 		slog.Warn("Logging requests to disk. Logs may include sensitive information. Disable this by setting logRequests: false.")
@@ -69,44 +113,44 @@ func SetupFile(shouldLog bool) error {
 		return nil
 	}
 
-	homeDir, err := util.UserHomeDir()
-	if err != nil {
-		return fmt.Errorf("failed to get user home directory: %w", err)
+	if logFile != nil {
+		logFile.SetLimits(limits)
+		return nil
 	}
 
-	logDir := filepath.Join(homeDir, "Library", "Logs", "Finicky")
-	err = os.MkdirAll(logDir, 0755) // Create directory if it doesn't exist
+	homeDir, err := util.UserHomeDir()
 	if err != nil {
-		return fmt.Errorf("failed to create log directory: %v", err)
+		return fmt.Errorf("failed to get user home directory: %w", err)
 	}
 
-	currentTime := time.Now().Format("2006-01-02_15-04-05.000")
-	logFile := filepath.Join(logDir, fmt.Sprintf("Finicky_%s.log", currentTime))
+	return startFile(filepath.Join(homeDir, "Library", "Logs", "Finicky"), limits)
+}
 
-	file, err = os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+// startFile opens the log file of the process in logDir and writes the buffered
+// records to it, masked by the current redaction
+func startFile(logDir string, limits RotationLimits) error {
+	file, err := newRotatingFile(logDir, limits, time.Now)
 	if err != nil {
-		return fmt.Errorf("failed to open log file: %v", err)
+		return err
 	}
+	logFile = file
 
-	slog.Info("Log file created", "path", logFile)
+	slog.Info("Log file created", "path", logFile.Path())
 
 	// Write buffered logs to file
-	if _, err := file.Write(memLog.Bytes()); err != nil {
+	if err := buffer.replay(context.Background(), newSinkHandler(logFile, settings.FileFormat, level), redactor); err != nil {
 		return fmt.Errorf("failed to write buffered logs: %v", err)
 	}
 
-	// Update writer to include file while preserving window writer
-	multiWriter := io.MultiWriter(file, memLog, os.Stdout, &windowWriter{})
-
-	// Update the default logger
-	slog.SetDefault(slog.New(createHandler(multiWriter)))
+	// Update the default logger to include the file
+	installHandler()
 	return nil
 }
 
 // Close properly closes the logger and any open file handles
 func Close() {
 	slog.Info("Application closed!")
-	if file != nil {
-		file.Close()
+	if logFile != nil {
+		logFile.Close()
 	}
 }
@@ -0,0 +1,211 @@
+package logger
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Redaction modes
+const (
+	// RedactParams masks sensitive query parameters, userinfo and fragments
+	RedactParams = "params"
+	// RedactHostsOnly only keeps the scheme and host of urls
+	RedactHostsOnly = "hosts"
+	// RedactOff logs urls as they are
+	RedactOff = "off"
+)
+
+// redacted replaces the masked parts of urls
+const redacted = "REDACTED"
+
+// defaultRedactedParams are query parameters that commonly carry credentials,
+// like OAuth codes, magic link tokens and signed url signatures
+var defaultRedactedParams = []string{
+	"code", "key", "auth", "sig", "otp", "jwt", "pwd", "ticket", "saml*",
+	"*token*", "*secret*", "*password*", "*passwd*", "*session*",
+	"*signature*", "*credential*", "*apikey*", "*api_key*",
+}
+
+// urlPattern finds urls in log messages and values, which end at whitespace or quotes
+var urlPattern = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"'<>]+`)
+
+// Redactor masks the sensitive parts of urls in log records
+type Redactor struct {
+	mode   string
+	params []string
+}
+
+// NewRedactor creates a redactor for a mode, masking the built-in query parameters
+// and extra ones, where "*" matches any characters
+func NewRedactor(mode string, extraParams []string) (*Redactor, error) {
+	switch mode {
+	case "":
+		mode = RedactParams
+	case RedactParams, RedactHostsOnly, RedactOff:
+	default:
+		return nil, fmt.Errorf("invalid log redaction %q, expected one of %s, %s or %s", mode, RedactParams, RedactHostsOnly, RedactOff)
+	}
+
+	params := make([]string, 0, len(defaultRedactedParams)+len(extraParams))
+	for _, pattern := range slices.Concat(defaultRedactedParams, extraParams) {
+		pattern = strings.ToLower(pattern)
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid redacted parameter pattern %q", pattern)
+		}
+		params = append(params, pattern)
+	}
+
+	return &Redactor{mode: mode, params: params}, nil
+}
+
+// RedactText masks the urls in a text
+func (r *Redactor) RedactText(text string) string {
+	if r == nil || r.mode == RedactOff || !strings.Contains(text, "://") {
+		return text
+	}
+	return urlPattern.ReplaceAllStringFunc(text, func(match string) string {
+		rawURL, trailing := splitTrailing(match)
+		return r.redactURL(rawURL) + trailing
+	})
+}
+
+// splitTrailing separates the punctuation and closing brackets that end the text
+// around a url from the url
+func splitTrailing(match string) (string, string) {
+	end := len(match)
+	for ; end > 0; end-- {
+		rawURL := match[:end]
+		switch c := rawURL[end-1]; {
+		case strings.IndexByte(".,;:!?", c) >= 0:
+		case c == ')' && strings.Count(rawURL, "(") < strings.Count(rawURL, ")"):
+		case c == ']' && strings.Count(rawURL, "[") < strings.Count(rawURL, "]"):
+		default:
+			return rawURL, match[end:]
+		}
+	}
+	return "", match
+}
+
+// redactRecord returns a copy of a record with the urls in its message and attributes masked
+func (r *Redactor) redactRecord(record slog.Record) slog.Record {
+	if r == nil || r.mode == RedactOff {
+		return record
+	}
+
+	redactedRecord := slog.NewRecord(record.Time, record.Level, r.RedactText(record.Message), record.PC)
+	record.Attrs(func(a slog.Attr) bool {
+		redactedRecord.AddAttrs(r.redactAttr(a))
+		return true
+	})
+	return redactedRecord
+}
+
+func (r *Redactor) redactAttrs(attrs []slog.Attr) []slog.Attr {
+	if r == nil || r.mode == RedactOff {
+		return attrs
+	}
+
+	redactedAttrs := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redactedAttrs[i] = r.redactAttr(a)
+	}
+	return redactedAttrs
+}
+
+// redactAttr masks the urls in an attribute. Values that aren't strings are
+// only replaced by their masked text when they contain urls to mask.
+func (r *Redactor) redactAttr(a slog.Attr) slog.Attr {
+	value := a.Value.Resolve()
+
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, r.RedactText(value.String()))
+	case slog.KindGroup:
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(r.redactAttrs(value.Group())...)}
+	case slog.KindAny:
+		text := fmt.Sprint(value.Any())
+		if redactedText := r.RedactText(text); redactedText != text {
+			return slog.String(a.Key, redactedText)
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: value}
+}
+
+// redactURL masks a url, or returns it as is when it can't be parsed
+func (r *Redactor) redactURL(rawURL string) string {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil || parsedURL.Host == "" {
+		return rawURL
+	}
+
+	if r.mode == RedactHostsOnly {
+		return parsedURL.Scheme + "://" + parsedURL.Host
+	}
+
+	changed := false
+	if parsedURL.User != nil {
+		parsedURL.User = url.User(redacted)
+		changed = true
+	}
+	if parsedURL.Fragment != "" {
+		parsedURL.Fragment = redacted
+		parsedURL.RawFragment = ""
+		changed = true
+	}
+	if query := r.redactQuery(parsedURL.RawQuery); query != parsedURL.RawQuery {
+		parsedURL.RawQuery = query
+		changed = true
+	}
+
+	// Keeps the url as it was written when there's nothing to mask
+	if !changed {
+		return rawURL
+	}
+	return parsedURL.String()
+}
+
+// redactQuery masks the values of sensitive parameters, and the urls in the values
+// of the others, keeping the rest of the query as it was written
+func (r *Redactor) redactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return rawQuery
+	}
+
+	params := strings.Split(rawQuery, "&")
+	for i, param := range params {
+		rawName, rawValue, hasValue := strings.Cut(param, "=")
+		name, err := url.QueryUnescape(rawName)
+		if err != nil {
+			name = rawName
+		}
+
+		if r.matchesParam(name) {
+			params[i] = rawName + "=" + redacted
+			continue
+		}
+
+		// Redirect wrappers carry the destination url in a parameter
+		if value, err := url.QueryUnescape(rawValue); hasValue && err == nil && strings.Contains(value, "://") {
+			if redactedValue := r.RedactText(value); redactedValue != value {
+				params[i] = rawName + "=" + url.QueryEscape(redactedValue)
+			}
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// matchesParam reports whether a query parameter is one to mask
+func (r *Redactor) matchesParam(name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range r.params {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,307 @@
+package logger
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	logFilePrefix     = "Finicky_"
+	logFileTimeFormat = "2006-01-02_15-04-05.000"
+)
+
+// RotationLimits bound how big log files get and how many are kept. A zero limit is disabled.
+type RotationLimits struct {
+	// MaxFileSize is the size in bytes a log file is rotated at
+	MaxFileSize int64
+	// MaxFileAge is how long a log file is written to before it's rotated
+	MaxFileAge time.Duration
+	// MaxFiles is the number of rotated log files kept
+	MaxFiles int
+	// MaxTotalSize is the size in bytes of all rotated log files kept
+	MaxTotalSize int64
+}
+
+// errFileInUse is returned when compressing a log file another process still writes to
+var errFileInUse = errors.New("log file is in use")
+
+// rotatingFile writes the logs of the process to a file in dir, moving on to a new
+// file when the current one reaches the limits. Rotated files, including the ones
+// left by earlier processes, are compressed and pruned in the background. The file
+// written to is locked, so other processes sharing the directory leave it alone.
+type rotatingFile struct {
+	mu     sync.Mutex
+	dir    string
+	limits RotationLimits
+	now    func() time.Time
+
+	file     *os.File
+	path     string
+	size     int64
+	openedAt time.Time
+
+	// pruning is held while rotated files are compressed and removed
+	pruning  sync.Mutex
+	cleanups sync.WaitGroup
+}
+
+// newRotatingFile opens a new log file in dir and cleans up the ones already there
+func newRotatingFile(dir string, limits RotationLimits, now func() time.Time) (*rotatingFile, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %v", err)
+	}
+
+	r := &rotatingFile{dir: dir, limits: limits, now: now}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	r.cleanupInBackground()
+	return r, nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if r.shouldRotate(len(p)) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Path returns the path of the file currently written to
+func (r *rotatingFile) Path() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.path
+}
+
+// SetLimits changes the limits, which apply from the next write on
+func (r *rotatingFile) SetLimits(limits RotationLimits) {
+	r.mu.Lock()
+	changed := r.limits != limits
+	r.limits = limits
+	r.mu.Unlock()
+
+	if changed {
+		r.cleanupInBackground()
+	}
+}
+
+// Close closes the file once the rotated files are cleaned up
+func (r *rotatingFile) Close() error {
+	r.cleanups.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// shouldRotate reports whether writing n more bytes needs a new file. A file is
+// never rotated empty, so a single large write can't rotate forever.
+func (r *rotatingFile) shouldRotate(n int) bool {
+	if r.size == 0 {
+		return false
+	}
+	if r.limits.MaxFileSize > 0 && r.size+int64(n) > r.limits.MaxFileSize {
+		return true
+	}
+	return r.limits.MaxFileAge > 0 && r.now().Sub(r.openedAt) >= r.limits.MaxFileAge
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %v", err)
+	}
+	r.file = nil
+
+	if err := r.open(); err != nil {
+		return err
+	}
+	r.cleanupInBackground()
+	return nil
+}
+
+// open starts a new log file named after the current time
+func (r *rotatingFile) open() error {
+	openedAt := r.now()
+	base := filepath.Join(r.dir, logFilePrefix+openedAt.Format(logFileTimeFormat))
+
+	path := base + ".log"
+	for i := 1; fileExists(path) || fileExists(path+".gz"); i++ {
+		path = fmt.Sprintf("%s_%d.log", base, i)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %v", err)
+	}
+	// The lock is released when the file is closed, or when the process exits
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		slog.Warn("Failed to lock log file", "path", path, "error", err)
+	}
+
+	r.file = file
+	r.path = path
+	r.size = 0
+	r.openedAt = openedAt
+	return nil
+}
+
+func (r *rotatingFile) cleanupInBackground() {
+	r.cleanups.Add(1)
+	go func() {
+		defer r.cleanups.Done()
+		r.cleanup()
+	}()
+}
+
+// cleanup compresses the log files no longer written to, skipping the ones other
+// running processes hold locked, then removes the oldest rotated files until the
+// rest are within the retention limits
+func (r *rotatingFile) cleanup() {
+	r.pruning.Lock()
+	defer r.pruning.Unlock()
+
+	r.mu.Lock()
+	activePath, limits := r.path, r.limits
+	r.mu.Unlock()
+
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return
+	}
+
+	type rotatedFile struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var rotated []rotatedFile
+
+	for _, entry := range entries {
+		name := entry.Name()
+		path := filepath.Join(r.dir, name)
+		if !strings.HasPrefix(name, logFilePrefix) || path == activePath {
+			continue
+		}
+
+		switch {
+		case strings.HasSuffix(name, ".log"):
+			compressedPath, err := compressFile(path)
+			if errors.Is(err, errFileInUse) {
+				continue
+			}
+			if err != nil {
+				slog.Warn("Failed to compress log file", "path", path, "error", err)
+				continue
+			}
+			path = compressedPath
+		case !strings.HasSuffix(name, ".log.gz"):
+			continue
+		}
+
+		if info, err := os.Stat(path); err == nil {
+			rotated = append(rotated, rotatedFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		}
+	}
+
+	// The newest files are kept
+	slices.SortFunc(rotated, func(a, b rotatedFile) int {
+		if c := b.modTime.Compare(a.modTime); c != 0 {
+			return c
+		}
+		return strings.Compare(b.path, a.path)
+	})
+
+	var totalSize int64
+	for i, file := range rotated {
+		totalSize += file.size
+		if (limits.MaxFiles > 0 && i >= limits.MaxFiles) || (limits.MaxTotalSize > 0 && totalSize > limits.MaxTotalSize) {
+			if err := os.Remove(file.path); err != nil && !os.IsNotExist(err) {
+				slog.Warn("Failed to remove log file", "path", file.path, "error", err)
+			}
+		}
+	}
+}
+
+// compressFile gzips a file next to it, removes the original and returns the path
+// of the compressed file, which keeps the modification time of the original. It
+// returns errFileInUse for a file another process holds locked.
+func compressFile(path string) (string, error) {
+	source, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer source.Close()
+
+	if err := syscall.Flock(int(source.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return "", errFileInUse
+		}
+		return "", err
+	}
+
+	info, err := source.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	compressedPath := path + ".gz"
+	tempFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(compressedPath)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tempFile.Name())
+
+	writer := gzip.NewWriter(tempFile)
+	writer.Name = filepath.Base(path)
+	writer.ModTime = info.ModTime()
+	if _, err := io.Copy(writer, source); err != nil {
+		tempFile.Close()
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		tempFile.Close()
+		return "", err
+	}
+	if err := tempFile.Close(); err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(tempFile.Name(), compressedPath); err != nil {
+		return "", err
+	}
+	os.Chtimes(compressedPath, info.ModTime(), info.ModTime())
+	source.Close()
+	return compressedPath, os.Remove(path)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,180 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile(t *testing.T) {
+	start := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		limits RotationLimits
+		// existing are log files left by earlier processes, from oldest to newest
+		existing []string
+		// locked are the existing files another running process still writes to
+		locked []int
+		writes []string
+		// tick is how much the clock moves between writes
+		tick time.Duration
+		// want are the contents of the log files kept, from oldest to newest
+		want []string
+	}{
+		{
+			name:   "no limits",
+			writes: []string{"one\n", "two\n"},
+			tick:   time.Hour,
+			want:   []string{"one\ntwo\n"},
+		},
+		{
+			name:   "rotates at the size limit",
+			limits: RotationLimits{MaxFileSize: 8},
+			writes: []string{"one\n", "two\n", "three\n"},
+			want:   []string{"one\ntwo\n", "three\n"},
+		},
+		{
+			name:   "writes larger than the size limit",
+			limits: RotationLimits{MaxFileSize: 2},
+			writes: []string{"one\n", "two\n"},
+			want:   []string{"one\n", "two\n"},
+		},
+		{
+			name:   "rotates at the age limit",
+			limits: RotationLimits{MaxFileAge: time.Hour},
+			writes: []string{"one\n", "two\n", "three\n", "four\n"},
+			tick:   40 * time.Minute,
+			want:   []string{"one\ntwo\n", "three\nfour\n"},
+		},
+		{
+			name:     "compresses the files of earlier processes",
+			existing: []string{"old\n"},
+			writes:   []string{"new\n"},
+			want:     []string{"old\n", "new\n"},
+		},
+		{
+			name:     "leaves the files other processes write to",
+			limits:   RotationLimits{MaxFiles: 1},
+			existing: []string{"old\n", "other\n"},
+			locked:   []int{1},
+			writes:   []string{"new\n"},
+			want:     []string{"old\n", "other\n", "new\n"},
+		},
+		{
+			name:     "keeps the newest files up to the count",
+			limits:   RotationLimits{MaxFileSize: 4, MaxFiles: 2},
+			existing: []string{"old\n", "older\n"},
+			writes:   []string{"one\n", "two\n", "three\n"},
+			want:     []string{"one\n", "two\n", "three\n"},
+		},
+		{
+			name:     "keeps the newest files up to the total size",
+			limits:   RotationLimits{MaxTotalSize: 150},
+			existing: []string{"a\n", "b\n", "c\n"},
+			writes:   []string{"d\n"},
+			// Each compressed file is around 60 bytes
+			want: []string{"b\n", "c\n", "d\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var livePaths []string
+			for i, content := range tt.existing {
+				createdAt := start.Add(time.Duration(i-len(tt.existing)) * time.Hour)
+				path := filepath.Join(dir, fmt.Sprintf("%s%s.log", logFilePrefix, createdAt.Format(logFileTimeFormat)))
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+				os.Chtimes(path, createdAt, createdAt)
+
+				if slices.Contains(tt.locked, i) {
+					liveFile, err := os.Open(path)
+					if err != nil {
+						t.Fatal(err)
+					}
+					defer liveFile.Close()
+					if err := syscall.Flock(int(liveFile.Fd()), syscall.LOCK_EX); err != nil {
+						t.Fatal(err)
+					}
+					livePaths = append(livePaths, path)
+				}
+			}
+
+			now := start
+			file, err := newRotatingFile(dir, tt.limits, func() time.Time { return now })
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Waits for the files of earlier processes to be compressed, which sets
+			// their modification times before the new ones are written
+			file.cleanups.Wait()
+
+			for _, write := range tt.writes {
+				if _, err := file.Write([]byte(write)); err != nil {
+					t.Fatal(err)
+				}
+				now = now.Add(tt.tick)
+			}
+			if err := file.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := readLogFiles(t, dir); !slices.Equal(got, tt.want) {
+				t.Errorf("log files = %q, want %q", got, tt.want)
+			}
+			for _, path := range livePaths {
+				if !fileExists(path) {
+					t.Errorf("%s was compressed or removed while in use", filepath.Base(path))
+				}
+			}
+		})
+	}
+}
+
+// readLogFiles returns the contents of the log files in dir by name, which sorts them
+// by when they were created
+func readLogFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var contents []string
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+
+		var reader io.Reader = file
+		switch {
+		case strings.HasSuffix(path, ".log.gz"):
+			if reader, err = gzip.NewReader(file); err != nil {
+				t.Fatalf("%s: %v", entry.Name(), err)
+			}
+		case !strings.HasSuffix(path, ".log"):
+			t.Fatalf("unexpected file %s", entry.Name())
+		}
+
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("%s: %v", entry.Name(), err)
+		}
+		contents = append(contents, string(data))
+	}
+	return contents
+}
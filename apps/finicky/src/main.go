@@ -11,13 +11,16 @@ package main
 import "C"
 
 import (
+	"context"
 	"embed"
 	"encoding/base64"
 	"encoding/json"
 	"finicky/browser"
 	"finicky/config"
 	"finicky/logger"
+	"finicky/schedule"
 	"finicky/shorturl"
+	"finicky/tracking"
 	"finicky/version"
 	"finicky/window"
 	"flag"
@@ -26,6 +29,8 @@ import (
 	"os"
 	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/dop251/goja"
@@ -62,17 +67,38 @@ type ConfigInfo struct {
 var urlListener chan URLInfo = make(chan URLInfo)
 var windowClosed chan struct{} = make(chan struct{})
 var vm *config.VM
+var shortURLResolver = shorturl.NewResolver()
+var shortURLCache *shorturl.Cache
+var trackingStripper *tracking.Stripper
 
 var forceWindowOpen bool = false
 var queueWindowOpen chan bool = make(chan bool)
 var lastError error
 var dryRun bool = false
+
+// logLevelFlag is the --log-level flag, which overrides the logLevel option
+var logLevelFlag string
 var updateInfo UpdateInfo
 var configInfo *ConfigInfo
+var configLoadedAt time.Time
+
+// configGeneration counts config loads, so lint warnings of a config replaced while
+// they were found aren't shown
+var configGeneration atomic.Int64
 var currentConfigState *config.ConfigState
 var shouldKeepRunning bool = true
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		os.Exit(runCheck(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "cache" {
+		os.Exit(runCache(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "shorteners" {
+		os.Exit(runShorteners(os.Args[2:]))
+	}
+
 	startTime := time.Now()
 	logger.Setup()
 	runtime.LockOSThread()
@@ -81,8 +107,16 @@ func main() {
 	configPathPtr := flag.String("config", "", "Path to custom configuration file")
 	windowPtr := flag.Bool("window", false, "Force window to open")
 	dryRunPtr := flag.Bool("dry-run", false, "Simulate without actually opening browsers")
+	flag.StringVar(&logLevelFlag, "log-level", "", "Least severe level of log messages to keep: debug, info, warn or error")
 	flag.Parse()
 
+	if logLevelFlag != "" {
+		if err := logger.Configure(logger.Settings{Level: logLevelFlag}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
 	// Use the parsed values
 	customConfigPath := *configPathPtr
 	if customConfigPath != "" {
@@ -119,18 +153,19 @@ func main() {
 		handleFatalError(fmt.Sprintf("Failed to setup config file watcher: %v", err))
 	}
 
-	vm, err = setupVM(cfw, embeddedFiles, namespace)
+	newVM, err := setupVM(cfw, embeddedFiles, namespace)
 	if err != nil {
 		handleFatalError(err.Error())
 	}
+	activateConfig(newVM)
 
 	slog.Debug("VM setup complete", "duration", fmt.Sprintf("%.2fms", float64(time.Since(startTime).Microseconds())/1000))
 
 	go checkForUpdates()
 
 	// Set up test URL handler
-	window.TestUrlHandler = func(url string) {
-		go TestURLInternal(url)
+	window.TestUrlHandler = func(url string, simulatedTime string) {
+		go TestURLInternal(url, simulatedTime)
 	}
 	window.EnableICloudSyncHandler = func() (interface{}, error) {
 		return cfw.EnableICloudSync()
@@ -141,6 +176,16 @@ func main() {
 	window.GetICloudSyncStatusHandler = func() (interface{}, error) {
 		return cfw.GetICloudSyncStatus()
 	}
+	window.ClearShortURLCacheHandler = func() (interface{}, error) {
+		count, err := clearShortURLCache()
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"ok":      true,
+			"message": fmt.Sprintf("Removed %d cached short URL(s)", count),
+		}, nil
+	}
 	window.GetChromiumProfilesHandler = func() (interface{}, error) {
 		return browser.ScanChromiumProfiles()
 	}
@@ -187,19 +232,18 @@ func main() {
 		if err != nil {
 			return nil, err
 		}
-		return &config.PreviewGeneratedConfigResult{
-			Ok:      true,
-			Content: config.BuildGeneratedConfigContent(request),
-		}, nil
+		return cfw.PreviewGeneratedConfig(request)
 	}
 
-	const oneDay = 24 * time.Hour
+	// The last check file decides when the update API is called, including retries
+	// after failures, so the schedule is looked at more often than updates are checked
+	const updateScheduleInterval = time.Hour
 
 	var showingWindow bool = false
 	timeoutChan := time.After(1 * time.Second)
-	updateChan := time.After(oneDay)
+	updateChan := time.After(updateScheduleInterval)
 
-	shouldKeepRunning = getConfigOption("keepRunning", true)
+	shouldKeepRunning = currentOptions().KeepRunning
 	if shouldKeepRunning {
 		timeoutChan = nil
 	}
@@ -216,10 +260,11 @@ func main() {
 				slog.Info("URL received", "url", url)
 
 				var browserConfig *browser.BrowserConfig
+				var evaluation *urlEvaluation
 				var err error
 
 				if vm != nil {
-					browserConfig, err = evaluateURL(vm.Runtime(), url, urlInfo.Opener)
+					browserConfig, evaluation, err = evaluateURL(vm, url, urlInfo.Opener, nil)
 					if err != nil {
 						handleRuntimeError(err)
 					}
@@ -242,6 +287,10 @@ func main() {
 					slog.Error("Failed to start browser", "error", err)
 				}
 
+				if evaluation != nil && len(evaluation.RedirectChain) > 1 {
+					slog.Info("URL routed", "browser", browserConfig.Name, "url", browserConfig.URL, "redirectChain", evaluation.RedirectChain)
+				}
+
 				slog.Debug("Time taken evaluating URL and opening browser", "duration", fmt.Sprintf("%.2fms", float64(time.Since(startTime).Microseconds())/1000))
 
 				if !showingWindow && !shouldKeepRunning {
@@ -252,14 +301,17 @@ func main() {
 
 			case <-configChange:
 				startTime := time.Now()
-				var setupErr error
 				slog.Debug("Config has changed")
-				vm, setupErr = setupVM(cfw, embeddedFiles, namespace)
+				// A broken config keeps the previous one running until it's fixed
+				newVM, setupErr := setupVM(cfw, embeddedFiles, namespace)
 				if setupErr != nil {
-					handleRuntimeError(setupErr)
+					handleConfigReloadError(setupErr)
+				} else {
+					activateConfig(newVM)
+					window.SendMessageToWebView("configReloadStatus", map[string]interface{}{})
 				}
 				slog.Debug("VM refresh complete", "duration", fmt.Sprintf("%.2fms", float64(time.Since(startTime).Microseconds())/1000))
-				shouldKeepRunning = getConfigOption("keepRunning", true)
+				shouldKeepRunning = currentOptions().KeepRunning
 
 			case shouldShowWindow := <-queueWindowOpen:
 				if !showingWindow && shouldShowWindow {
@@ -270,7 +322,7 @@ func main() {
 
 			case <-updateChan:
 				go checkForUpdates()
-				updateChan = time.After(oneDay)
+				updateChan = time.After(updateScheduleInterval)
 
 			case <-windowClosed:
 				if !shouldKeepRunning {
@@ -287,7 +339,7 @@ func main() {
 		}
 	}()
 
-	hideIcon := getConfigOption("hideIcon", false)
+	hideIcon := currentOptions().HideIcon
 
 	C.RunApp(C.bool(forceWindowOpen), C.bool(!hideIcon), C.bool(shouldKeepRunning))
 }
@@ -298,21 +350,102 @@ func handleRuntimeError(err error) {
 	go QueueWindowDisplay(1)
 }
 
-func getConfigOption(optionName string, defaultValue bool) bool {
-	if vm == nil || vm.Runtime() == nil {
-		slog.Debug("VM not initialized, returning default for config option", "option", optionName, "default", defaultValue)
-		return defaultValue
+// handleConfigReloadError reports a config that failed to reload, and which
+// config is handling urls in the meantime
+func handleConfigReloadError(err error) {
+	status := map[string]interface{}{
+		"error": err.Error(),
 	}
 
-	script := fmt.Sprintf("finickyConfigAPI.getOption('%s', finalConfig, %t)", optionName, defaultValue)
-	optionVal, err := vm.Runtime().RunString(script)
+	if vm != nil {
+		since := configLoadedAt.Format("15:04")
+		slog.Error("Failed to reload config, running previous config", "since", since, "error", err)
+		status["previousConfigSince"] = since
+	} else {
+		slog.Error("Failed to reload config", "error", err)
+	}
 
-	if err != nil {
-		slog.Error("Failed to get config option", "option", optionName, "error", err)
-		return defaultValue
+	lastError = err
+	window.SendMessageToWebView("configReloadStatus", status)
+	go QueueWindowDisplay(1)
+}
+
+// activateConfig makes a loaded config the one urls are evaluated with
+func activateConfig(newVM *config.VM) {
+	vm = newVM
+	options := currentOptions()
+	shortURLResolver = newShortURLResolver(options)
+
+	trackingStripper = nil
+	if options.StripTrackingParams {
+		trackingStripper = tracking.NewStripper(options.TrackingParams, options.TrackingParamExceptions)
+	}
+}
+
+// newShortURLResolver creates a short url resolver with the policy set in the config options
+func newShortURLResolver(options config.Options) *shorturl.Resolver {
+	var cache *shorturl.Cache
+	if options.ShortURLCacheSize > 0 {
+		if shortURLCache == nil {
+			shortURLCache = shorturl.NewCache(config.CachePath(shortURLCacheFile), options.ShortURLCacheSize)
+		}
+		shortURLCache.SetMaxEntries(options.ShortURLCacheSize)
+		cache = shortURLCache
+	}
+
+	return shortURLResolverWith(options, loadShortenerRegistry(options.ShortenerRegistry), cache)
+}
+
+// shortURLResolverWith creates a short url resolver with the policy set in the
+// config options, the given registry and cache, either of which may be nil
+func shortURLResolverWith(options config.Options, registry *shorturl.Registry, cache *shorturl.Cache) *shorturl.Resolver {
+	return &shorturl.Resolver{
+		Timeout:         time.Duration(options.ShortURLTimeoutMs) * time.Millisecond,
+		MaxRedirects:    options.ShortURLMaxRedirects,
+		Methods:         options.ShortURLMethods,
+		UserAgent:       options.ShortURLUserAgent,
+		Headers:         options.ShortURLHeaders,
+		ExtraDomains:    options.URLShorteners,
+		DisabledDomains: options.DisabledURLShorteners,
+		Registry:        registry,
+		Cache:           cache,
+	}
+}
+
+// logSettings returns the log level and formats set in the config options, with
+// the --log-level flag taking precedence over the logLevel option
+func logSettings(options config.Options) logger.Settings {
+	level := options.LogLevel
+	if logLevelFlag != "" {
+		level = logLevelFlag
+	}
+	return logger.Settings{
+		Level:        level,
+		StdoutFormat: options.LogStdoutFormat,
+		FileFormat:   options.LogFileFormat,
+		WindowFormat: options.LogWindowFormat,
+
+		Redaction:      options.LogRedaction,
+		RedactedParams: options.LogRedactedParams,
 	}
+}
 
-	return optionVal.ToBoolean()
+// logRotationLimits returns the log file limits set in the config options
+func logRotationLimits(options config.Options) logger.RotationLimits {
+	return logger.RotationLimits{
+		MaxFileSize:  int64(options.LogFileMaxSizeMb) << 20,
+		MaxFileAge:   time.Duration(options.LogFileMaxAgeHours) * time.Hour,
+		MaxFiles:     options.LogRetentionCount,
+		MaxTotalSize: int64(options.LogRetentionSizeMb) << 20,
+	}
+}
+
+// currentOptions returns the options of the running config, or the defaults when there is none
+func currentOptions() config.Options {
+	if vm == nil {
+		return config.DefaultOptions()
+	}
+	return vm.Options()
 }
 
 //export HandleURL
@@ -350,10 +483,10 @@ func HandleURL(url *C.char, name *C.char, bundleId *C.char, path *C.char, openIn
 //export TestURL
 func TestURL(url *C.char) {
 	urlString := C.GoString(url)
-	TestURLInternal(urlString)
+	TestURLInternal(urlString, "")
 }
 
-func TestURLInternal(urlString string) {
+func TestURLInternal(urlString string, simulatedTime string) {
 	slog.Debug("Testing URL", "url", urlString)
 
 	if vm == nil {
@@ -364,7 +497,20 @@ func TestURLInternal(urlString string) {
 		return
 	}
 
-	browserConfig, err := evaluateURL(vm.Runtime(), urlString, nil)
+	var clock schedule.Clock
+	if simulatedTime != "" {
+		at, err := time.Parse(time.RFC3339, simulatedTime)
+		if err != nil {
+			window.SendMessageToWebView("testUrlResult", map[string]interface{}{
+				"error": fmt.Sprintf("Invalid simulated time: %v", err),
+			})
+			return
+		}
+		slog.Debug("Simulating time", "time", at)
+		clock = func() time.Time { return at }
+	}
+
+	browserConfig, evaluation, err := evaluateURL(vm, urlString, nil, clock)
 	if err != nil {
 		slog.Error("Failed to evaluate URL", "error", err)
 		window.SendMessageToWebView("testUrlResult", map[string]interface{}{
@@ -386,21 +532,72 @@ func TestURLInternal(urlString string) {
 		"openInBackground": browserConfig.OpenInBackground,
 		"profile":          browserConfig.Profile,
 		"args":             browserConfig.Args,
+		"strippedParams":   evaluation.StrippedParams,
+		"redirectChain":    evaluation.RedirectChain,
 	})
 }
 
-func evaluateURL(vm *goja.Runtime, url string, opener *ProcessInfo) (*browser.BrowserConfig, error) {
-	resolvedURL, err := shorturl.ResolveURL(url)
+// urlEvaluation records what happened to a url before the config chose a browser for it
+type urlEvaluation struct {
+	// StrippedParams are the tracking parameters removed from the url
+	StrippedParams []string
+	// RedirectChain are the urls the url passed through before the config saw it
+	RedirectChain []shorturl.Hop
+}
+
+// redirectChainValue converts a redirect chain to the plain objects the config sees
+func redirectChainValue(chain []shorturl.Hop) []map[string]interface{} {
+	hops := make([]map[string]interface{}, len(chain))
+	for i, hop := range chain {
+		hops[i] = map[string]interface{}{
+			"url":    hop.URL,
+			"status": hop.Status,
+			"host":   hop.Host,
+		}
+	}
+	return hops
+}
+
+// evaluating is held while a url is evaluated, as tested urls and opened ones share
+// the VM and each sets its own clock and url resolver on it
+var evaluating sync.Mutex
+
+// evaluateURL runs the config for a url with the time of clock, or the system time when it's nil
+func evaluateURL(configVM *config.VM, url string, opener *ProcessInfo, clock schedule.Clock) (*browser.BrowserConfig, *urlEvaluation, error) {
+	evaluating.Lock()
+	defer evaluating.Unlock()
+
+	vm := configVM.Runtime()
+	options := configVM.Options()
+	evaluation := &urlEvaluation{StrippedParams: []string{}, RedirectChain: []shorturl.Hop{}}
+
+	configVM.SetClock(clock)
+	defer configVM.SetClock(nil)
+
+	resolution := newURLResolution(shortURLResolver, options.SkipShortURLResolutionFor, opener)
+	configVM.SetURLResolver(resolution.resolve)
+	defer configVM.SetURLResolver(nil)
+
 	vm.Set("originalUrl", url)
 
-	if err != nil {
-		// Continue with original URL if resolution fails
-		slog.Info("Failed to resolve short URL", "error", err, "url", url, "using", resolvedURL)
+	if options.ShortURLResolution == "lazy" {
+		// Unwrapping needs no network, short urls are only resolved when the config calls finicky.resolveUrl
+		url, evaluation.RedirectChain = shorturl.UnwrapChain(url)
+	} else {
+		url, evaluation.RedirectChain = resolution.resolveChain(url)
 	}
+	vm.Set("redirectChain", redirectChainValue(evaluation.RedirectChain))
 
-	url = resolvedURL
+	// Built-in rewrites run before the ones in the config
+	if trackingStripper != nil {
+		if strippedURL, stripped := trackingStripper.Strip(url); len(stripped) > 0 {
+			slog.Debug("Stripped tracking parameters", "params", stripped, "url", strippedURL)
+			url = strippedURL
+			evaluation.StrippedParams = stripped
+		}
+	}
 
-	vm.Set("url", resolvedURL)
+	vm.Set("url", url)
 
 	if opener != nil {
 		vm.Set("opener", map[string]interface{}{
@@ -414,21 +611,28 @@ func evaluateURL(vm *goja.Runtime, url string, opener *ProcessInfo) (*browser.Br
 		slog.Debug("No opener detected")
 	}
 
-	openResult, err := vm.RunString("finickyConfigAPI.openUrl(url, opener, originalUrl, finalConfig)")
+	// Only the config counts against the timeout, not resolving the url before it runs
+	stopTimeout := interruptAfter(vm, options.EvaluationTimeoutMs)
+	openResult, err := vm.RunString("finickyConfigAPI.openUrl(url, opener, originalUrl, finalConfig, redirectChain)")
+	stopTimeout()
+	if options.ShortURLResolution == "lazy" {
+		// The short urls the config resolved are where the url went too
+		evaluation.RedirectChain = resolution.redirectChain(evaluation.RedirectChain)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to evaluate URL in config: %v", err)
+		return nil, evaluation, fmt.Errorf("failed to evaluate URL in config: %v", config.ConfigErrorFromException(err))
 	}
 
 	resultJSON := openResult.ToObject(vm).Export()
 	resultBytes, err := json.Marshal(resultJSON)
 	if err != nil {
-		return nil, fmt.Errorf("failed to process browser configuration: %v", err)
+		return nil, evaluation, fmt.Errorf("failed to process browser configuration: %v", err)
 	}
 
 	var browserResult browser.BrowserResult
 
 	if err := json.Unmarshal(resultBytes, &browserResult); err != nil {
-		return nil, fmt.Errorf("failed to parse browser configuration: %v", err)
+		return nil, evaluation, fmt.Errorf("failed to parse browser configuration: %v", err)
 	}
 
 	slog.Debug("Final browser options",
@@ -438,11 +642,45 @@ func evaluateURL(vm *goja.Runtime, url string, opener *ProcessInfo) (*browser.Br
 		"args", browserResult.Browser.Args,
 		"appType", browserResult.Browser.AppType,
 	)
+	if browserResult.MatchedHandler != nil {
+		slog.Debug("Matched handler",
+			"index", browserResult.MatchedHandler.Index,
+			"source", browserResult.MatchedHandler.Source,
+		)
+	}
+
 	var resultErr error
 	if browserResult.Error != "" {
-		resultErr = fmt.Errorf("%s", browserResult.Error)
+		resultErr = config.NewConfigError(browserResult.Error, browserResult.ErrorStack)
+	}
+	return &browserResult.Browser, evaluation, resultErr
+}
+
+// interruptAfter interrupts the config when it runs longer than timeoutMs, unless
+// it's zero. The returned function stops the timeout and clears an interrupt that
+// came too late, which would otherwise abort the next script the VM runs.
+func interruptAfter(vm *goja.Runtime, timeoutMs int) func() {
+	if timeoutMs <= 0 {
+		return func() {}
+	}
+
+	var mu sync.Mutex
+	stopped := false
+	timer := time.AfterFunc(time.Duration(timeoutMs)*time.Millisecond, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if !stopped {
+			vm.Interrupt(fmt.Sprintf("config took longer than %dms to choose a browser", timeoutMs))
+		}
+	})
+
+	return func() {
+		mu.Lock()
+		stopped = true
+		mu.Unlock()
+		timer.Stop()
+		vm.ClearInterrupt()
 	}
-	return &browserResult.Browser, resultErr
 }
 
 func handleFatalError(errorMessage string) {
@@ -482,13 +720,21 @@ func GetCurrentConfigPath() *C.char {
 	}
 }
 
+// updateCheck is held while updates are checked, so a scheduled check doesn't overlap the one at launch
+var updateCheck sync.Mutex
+
 func checkForUpdates() {
-	var runtime *goja.Runtime
-	if vm != nil {
-		runtime = vm.Runtime()
+	if !updateCheck.TryLock() {
+		return
 	}
+	defer updateCheck.Unlock()
 
-	releaseInfo, updateCheckEnabled, err := version.CheckForUpdatesIfEnabled(runtime)
+	options := currentOptions()
+	releaseInfo, updateCheckEnabled, err := version.CheckForUpdatesIfEnabled(version.UpdateSettings{
+		Enabled: options.CheckForUpdates,
+		Channel: options.UpdateChannel,
+		APIHost: options.UpdateAPIHost,
+	})
 	if err != nil {
 		slog.Error("Error checking for updates", "error", err)
 	}
@@ -500,6 +746,7 @@ func checkForUpdates() {
 
 	if updateInfo.ReleaseInfo != nil && updateInfo.ReleaseInfo.HasUpdate {
 		slog.Info("New version is available", "version", updateInfo.ReleaseInfo.LatestVersion)
+		go downloadUpdate(*updateInfo.ReleaseInfo)
 	}
 
 	if updateInfo.ReleaseInfo != nil {
@@ -509,6 +756,10 @@ func checkForUpdates() {
 			"updateCheckEnabled": updateInfo.UpdateCheckEnabled,
 			"downloadUrl":        updateInfo.ReleaseInfo.DownloadUrl,
 			"releaseUrl":         updateInfo.ReleaseInfo.ReleaseUrl,
+			"releaseNotes":       updateInfo.ReleaseInfo.ReleaseNotes,
+			"publishedAt":        updateInfo.ReleaseInfo.PublishedAt,
+			"minimumOsVersion":   updateInfo.ReleaseInfo.MinimumOSVersion,
+			"prerelease":         updateInfo.ReleaseInfo.Prerelease,
 		})
 	} else {
 		window.SendMessageToWebView("updateInfo", map[string]interface{}{
@@ -521,37 +772,67 @@ func checkForUpdates() {
 	}
 }
 
+// updateDownload is held while an update downloads, so later checks don't start another download
+var updateDownload sync.Mutex
+
+// downloadUpdate downloads and verifies an update to the staging directory,
+// reporting its progress to the window
+func downloadUpdate(release version.ReleaseInfo) {
+	if !updateDownload.TryLock() {
+		return
+	}
+	defer updateDownload.Unlock()
+
+	updater, err := version.NewUpdater(func(progress version.UpdateProgress) {
+		window.SendMessageToWebView("updateProgress", progress)
+	})
+	if err != nil {
+		slog.Debug("Not downloading update", "error", err)
+		return
+	}
+
+	if _, err := updater.Download(context.Background(), release); err != nil {
+		slog.Warn("Failed to download update", "version", release.LatestVersion, "error", err)
+	}
+}
+
+// tearDown exits right away, update checks only run in the background so they
+// never hold up quitting
 func tearDown() {
-	checkForUpdates()
 	slog.Info("Exiting...")
 	os.Exit(0)
 }
 
+// setupVM bundles and loads the config into a new VM. It doesn't replace the
+// global vm, so callers can keep the current one when the new config fails.
 func setupVM(cfw *config.ConfigFileWatcher, embeddedFS embed.FS, namespace string) (*config.VM, error) {
-	logRequests := true
-	var err error
-
-	defer func() {
-		err = logger.SetupFile(logRequests)
-		if err != nil {
-			slog.Warn("Failed to setup file logging", "error", err)
-		}
-	}()
+	// Keeps logging as configured by the running config when the new one fails, and
+	// logs to a file with the default options when there's no config yet
+	keepLogging := func() {
+		setupLogging(currentOptions(), vm == nil || currentOptions().LogRequests)
+	}
 
 	currentBundlePath, configPath, err := cfw.BundleConfig()
 
 	if err != nil {
+		keepLogging()
 		return nil, fmt.Errorf("failed to read config: %v", err)
 	}
 
 	if currentBundlePath != "" {
-		vm, err = config.New(embeddedFS, namespace, currentBundlePath)
+		newVM, err := config.New(embeddedFS, namespace, currentBundlePath)
 
 		if err != nil {
+			keepLogging()
 			return nil, fmt.Errorf("failed to setup VM: %v", err)
 		}
 
-		currentConfigState = vm.GetConfigState()
+		// Applies the log options, including redaction, before anything else is logged with them
+		options := newVM.Options()
+		setupLogging(options, options.LogRequests)
+
+		configLoadedAt = time.Now()
+		currentConfigState = newVM.GetConfigState()
 
 		if currentConfigState != nil {
 			configInfo = &ConfigInfo{
@@ -562,30 +843,53 @@ func setupVM(cfw *config.ConfigFileWatcher, embeddedFS embed.FS, namespace strin
 			}
 		}
 
-		keepRunning := getConfigOption("keepRunning", true)
-		hideIcon := getConfigOption("hideIcon", false)
-		logRequests = getConfigOption("logRequests", false)
-		checkForUpdates := getConfigOption("checkForUpdates", true)
-
 		window.SendMessageToWebView("config", map[string]interface{}{
 			"handlers":       configInfo.Handlers,
 			"rewrites":       configInfo.Rewrites,
 			"defaultBrowser": configInfo.DefaultBrowser,
 			"configPath":     configInfo.ConfigPath,
-			"options": map[string]interface{}{
-				"keepRunning":     keepRunning,
-				"hideIcon":        hideIcon,
-				"logRequests":     logRequests,
-				"checkForUpdates": checkForUpdates,
-			},
+			"options":        options,
 		})
 
-		return vm, nil
+		// Linting looks up installed apps and reads the config files, so it runs in
+		// the background and doesn't hold up handling urls
+		lint := newVM.Lint(configPath)
+		generation := configGeneration.Add(1)
+		go func() {
+			warnings := lint()
+			if configGeneration.Load() != generation {
+				return
+			}
+			for _, warning := range warnings {
+				slog.Warn("Config warning", "warning", warning.Error())
+			}
+			if warnings == nil {
+				warnings = config.SourceErrors{}
+			}
+			window.SendMessageToWebView("configWarnings", map[string]interface{}{
+				"configPath": configPath,
+				"warnings":   warnings,
+			})
+		}()
+
+		return newVM, nil
 	}
 
+	keepLogging()
 	return nil, nil
 }
 
+// setupLogging applies the log level, formats and redaction of the config options,
+// and starts logging to a file when logToFile is set
+func setupLogging(options config.Options, logToFile bool) {
+	if err := logger.Configure(logSettings(options)); err != nil {
+		slog.Warn("Invalid log settings", "error", err)
+	}
+	if err := logger.SetupFile(logToFile, logRotationLimits(options)); err != nil {
+		slog.Warn("Failed to setup file logging", "error", err)
+	}
+}
+
 func getConfigBuilderDraft(runtime *goja.Runtime) (map[string]interface{}, error) {
 	draftVal, err := runtime.RunString("finickyConfigAPI.getConfigBuilderDraft(finalConfig)")
 	if err != nil {
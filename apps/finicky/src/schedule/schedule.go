@@ -0,0 +1,213 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// Embed the IANA time zone database so time zones resolve even when the
+	// system database is unavailable
+	_ "time/tzdata"
+)
+
+// Clock returns the current time. It is injected so that helpers can be
+// evaluated deterministically in tests and simulated from the test URL panel.
+type Clock func() time.Time
+
+// SystemClock returns the current wall clock time
+func SystemClock() time.Time {
+	return time.Now()
+}
+
+// Schedule describes a recurring weekly time window, e.g. weekdays between 09:00 and 18:00
+type Schedule struct {
+	Days []string `json:"days"`
+	From string   `json:"from"`
+	To   string   `json:"to"`
+	TZ   string   `json:"tz"`
+}
+
+// Moment is a point in time broken down into calendar fields for a time zone
+type Moment struct {
+	Time     time.Time
+	Year     int
+	Month    int
+	Day      int
+	Weekday  string
+	Hour     int
+	Minute   int
+	Second   int
+	Timezone string
+}
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Now returns the current time of the clock in the given time zone. An empty
+// time zone uses the local time zone.
+func Now(clock Clock, tz string) (Moment, error) {
+	if clock == nil {
+		clock = SystemClock
+	}
+
+	location, err := loadLocation(tz)
+	if err != nil {
+		return Moment{}, err
+	}
+
+	t := clock().In(location)
+	return Moment{
+		Time:     t,
+		Year:     t.Year(),
+		Month:    int(t.Month()),
+		Day:      t.Day(),
+		Weekday:  weekdayNames[t.Weekday()],
+		Hour:     t.Hour(),
+		Minute:   t.Minute(),
+		Second:   t.Second(),
+		Timezone: location.String(),
+	}, nil
+}
+
+// Contains reports whether t falls within the schedule. Windows where "to" is
+// earlier than "from" wrap past midnight and belong to the day they started on.
+func (s Schedule) Contains(t time.Time) (bool, error) {
+	location, err := loadLocation(s.TZ)
+	if err != nil {
+		return false, err
+	}
+
+	days, err := parseDays(s.Days)
+	if err != nil {
+		return false, err
+	}
+
+	from, err := parseClockTime(s.From, 0)
+	if err != nil {
+		return false, fmt.Errorf("invalid \"from\" time: %w", err)
+	}
+
+	to, err := parseClockTime(s.To, 24*60)
+	if err != nil {
+		return false, fmt.Errorf("invalid \"to\" time: %w", err)
+	}
+
+	t = t.In(location)
+	minute := t.Hour()*60 + t.Minute()
+	weekday := t.Weekday()
+
+	if from <= to {
+		return days[weekday] && minute >= from && minute < to, nil
+	}
+
+	// Overnight window, e.g. 22:00-06:00
+	if minute >= from {
+		return days[weekday], nil
+	}
+	if minute < to {
+		return days[(weekday+6)%7], nil
+	}
+	return false, nil
+}
+
+// IsWithin reports whether the clock's current time falls within the schedule
+func IsWithin(clock Clock, s Schedule) (bool, error) {
+	if clock == nil {
+		clock = SystemClock
+	}
+	return s.Contains(clock())
+}
+
+func loadLocation(tz string) (*time.Location, error) {
+	if tz == "" || strings.EqualFold(tz, "local") {
+		return time.Local, nil
+	}
+
+	location, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", tz)
+	}
+	return location, nil
+}
+
+// parseDays parses day names ("mon") and ranges ("mon-fri", "fri-mon") into a
+// lookup table indexed by time.Weekday. No days means every day.
+func parseDays(specs []string) ([7]bool, error) {
+	var days [7]bool
+
+	if len(specs) == 0 {
+		for i := range days {
+			days[i] = true
+		}
+		return days, nil
+	}
+
+	for _, spec := range specs {
+		spec = strings.ToLower(strings.TrimSpace(spec))
+		startName, endName, isRange := strings.Cut(spec, "-")
+
+		start, err := parseWeekday(startName)
+		if err != nil {
+			return days, err
+		}
+
+		if !isRange {
+			days[start] = true
+			continue
+		}
+
+		end, err := parseWeekday(endName)
+		if err != nil {
+			return days, err
+		}
+
+		for day := start; ; day = (day + 1) % 7 {
+			days[day] = true
+			if day == end {
+				break
+			}
+		}
+	}
+
+	return days, nil
+}
+
+// parseWeekday parses a short ("mon") or full ("monday") day name
+func parseWeekday(name string) (time.Weekday, error) {
+	name = strings.TrimSpace(name)
+	for i, weekday := range weekdayNames {
+		if name == weekday || name == strings.ToLower(time.Weekday(i).String()) {
+			return time.Weekday(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown day %q", name)
+}
+
+// parseClockTime parses "HH:MM" into minutes since midnight. "24:00" is allowed
+// to mark the end of the day.
+func parseClockTime(value string, defaultValue int) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	hourPart, minutePart, ok := strings.Cut(value, ":")
+	if !ok {
+		return 0, fmt.Errorf("expected HH:MM, got %q", value)
+	}
+
+	hour, err := strconv.Atoi(hourPart)
+	if err != nil {
+		return 0, fmt.Errorf("expected HH:MM, got %q", value)
+	}
+	minute, err := strconv.Atoi(minutePart)
+	if err != nil {
+		return 0, fmt.Errorf("expected HH:MM, got %q", value)
+	}
+
+	if hour < 0 || minute < 0 || minute > 59 || hour > 24 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("time out of range: %q", value)
+	}
+
+	return hour*60 + minute, nil
+}
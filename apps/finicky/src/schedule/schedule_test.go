@@ -0,0 +1,72 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleContains(t *testing.T) {
+	stockholm, err := time.LoadLocation("Europe/Stockholm")
+	if err != nil {
+		t.Fatalf("failed to load time zone: %v", err)
+	}
+
+	workHours := Schedule{Days: []string{"mon-fri"}, From: "09:00", To: "18:00", TZ: "Europe/Stockholm"}
+	nightShift := Schedule{Days: []string{"fri"}, From: "22:00", To: "06:00", TZ: "Europe/Stockholm"}
+	weekend := Schedule{Days: []string{"sat", "sun"}, TZ: "Europe/Stockholm"}
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+		time     time.Time
+		expected bool
+		wantErr  bool
+	}{
+		{"weekday morning", workHours, time.Date(2025, 3, 3, 9, 0, 0, 0, stockholm), true, false},
+		{"weekday before start", workHours, time.Date(2025, 3, 3, 8, 59, 0, 0, stockholm), false, false},
+		{"weekday at end", workHours, time.Date(2025, 3, 7, 18, 0, 0, 0, stockholm), false, false},
+		{"saturday", workHours, time.Date(2025, 3, 8, 12, 0, 0, 0, stockholm), false, false},
+		{"other time zone", workHours, time.Date(2025, 3, 3, 8, 30, 0, 0, time.UTC), true, false},
+		{"overnight start day", nightShift, time.Date(2025, 3, 7, 23, 0, 0, 0, stockholm), true, false},
+		{"overnight next morning", nightShift, time.Date(2025, 3, 8, 5, 59, 0, 0, stockholm), true, false},
+		{"overnight wrong day", nightShift, time.Date(2025, 3, 7, 5, 0, 0, 0, stockholm), false, false},
+		{"whole day", weekend, time.Date(2025, 3, 9, 23, 59, 0, 0, stockholm), true, false},
+		{"wrapping day range", Schedule{Days: []string{"fri-mon"}}, time.Date(2025, 3, 9, 12, 0, 0, 0, time.Local), true, false},
+		{"full day names", Schedule{Days: []string{"Saturday-Sunday"}}, time.Date(2025, 3, 9, 12, 0, 0, 0, time.Local), true, false},
+		{"unknown day", Schedule{Days: []string{"someday"}}, time.Now(), false, true},
+		{"day name prefix", Schedule{Days: []string{"monkey"}}, time.Now(), false, true},
+		{"unknown time zone", Schedule{TZ: "Mars/Olympus"}, time.Now(), false, true},
+		{"invalid time", Schedule{From: "9am"}, time.Now(), false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.schedule.Contains(tt.time)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Contains() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.expected {
+				t.Errorf("Contains() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNow(t *testing.T) {
+	clock := func() time.Time {
+		return time.Date(2025, 6, 1, 22, 30, 15, 0, time.UTC)
+	}
+
+	moment, err := Now(clock, "Europe/Stockholm")
+	if err != nil {
+		t.Fatalf("Now() error = %v", err)
+	}
+
+	if moment.Day != 2 || moment.Hour != 0 || moment.Minute != 30 || moment.Weekday != "mon" {
+		t.Errorf("Now() = %+v, want Monday 2 June 00:30", moment)
+	}
+	if moment.Timezone != "Europe/Stockholm" {
+		t.Errorf("Now() timezone = %s, want Europe/Stockholm", moment.Timezone)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"crypto/sha256"
+	"finicky/config"
+	"finicky/shorturl"
+	"finicky/util"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var shortenerRegistry *shorturl.Registry
+
+// loadShortenerRegistry returns the registry set in the config options, reusing the
+// loaded one while the source doesn't change, and refreshes it in the background
+// when it's stale. It returns nil when no registry is set.
+func loadShortenerRegistry(source string) *shorturl.Registry {
+	if source == "" {
+		shortenerRegistry = nil
+		return nil
+	}
+
+	source = expandRegistrySource(source)
+	if shortenerRegistry == nil || shortenerRegistry.Source() != source {
+		shortenerRegistry = shorturl.NewRegistry(source, shortenerRegistryCachePath(source))
+	}
+
+	if registry := shortenerRegistry; registry.Stale() {
+		go func() {
+			if _, err := registry.Refresh(); err != nil {
+				slog.Warn("Failed to refresh shortener registry", "source", registry.Source(), "error", err)
+			}
+		}()
+	}
+	return shortenerRegistry
+}
+
+// expandRegistrySource expands a registry path starting with ~/ to the home directory
+func expandRegistrySource(source string) string {
+	if strings.HasPrefix(source, "~/") {
+		if homeDir, err := util.UserHomeDir(); err == nil {
+			return filepath.Join(homeDir, source[2:])
+		}
+	}
+	return source
+}
+
+// shortenerRegistryCachePath returns where the last download of a registry is
+// kept, by source so switching registries never uses the domains of another one
+func shortenerRegistryCachePath(source string) string {
+	return config.CachePath(fmt.Sprintf("shortener_registry_%x.json", sha256.Sum256([]byte(source))))
+}
+
+// runShorteners implements `finicky shorteners test <url>`, which explains whether
+// and how a url would be resolved, and `finicky shorteners refresh`, which updates
+// the shortener registry set in the config. It returns the process exit code.
+func runShorteners(args []string) int {
+	flags := flag.NewFlagSet("shorteners", flag.ContinueOnError)
+	configPath := flags.String("config", "", "Path of the config to use")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: finicky shorteners test <url> [-config path]")
+		fmt.Fprintln(flags.Output(), "       finicky shorteners refresh [-config path]")
+		flags.PrintDefaults()
+	}
+
+	if len(args) == 0 {
+		flags.Usage()
+		return 2
+	}
+	command := args[0]
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	// Allows flags after the url too
+	var positional []string
+	for flags.NArg() > 0 {
+		positional = append(positional, flags.Arg(0))
+		if err := flags.Parse(flags.Args()[1:]); err != nil {
+			return 2
+		}
+	}
+
+	options, loadedPath, err := config.LoadOptions(embeddedFiles, "finickyConfig", *configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Using default options, failed to load config: %v\n", err)
+	} else {
+		fmt.Printf("Config: %s\n", loadedPath)
+	}
+
+	switch {
+	case command == "test" && len(positional) == 1:
+		return testShortener(options, positional[0])
+	case command == "refresh" && len(positional) == 0:
+		return refreshShortenerRegistry(options)
+	default:
+		flags.Usage()
+		return 2
+	}
+}
+
+// testShortener prints whether a url is on a shortener domain and where it leads
+func testShortener(options config.Options, rawURL string) int {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil || parsedURL.Host == "" {
+		fmt.Fprintf(os.Stderr, "Invalid URL: %s\n", rawURL)
+		return 2
+	}
+
+	// Shows what the network says now rather than what was cached, and leaves the
+	// cache of the running app alone
+	resolver := shortURLResolverWith(options, currentShortenerRegistry(options.ShortenerRegistry), nil)
+
+	unwrappedURL, _ := shorturl.Unwrap(rawURL)
+	if unwrappedURL != rawURL {
+		fmt.Printf("Unwrapped: %s\n", unwrappedURL)
+		parsedURL, _ = url.Parse(unwrappedURL)
+	}
+
+	match, ok := resolver.MatchShortener(parsedURL.Host)
+	switch {
+	case ok:
+		fmt.Printf("Shortener: %s matches %s (%s)\n", parsedURL.Host, match.Domain, match.Source)
+	case match.Source == shorturl.SourceDisabled:
+		fmt.Printf("Shortener: %s matches %s but it's disabled in the config\n", parsedURL.Host, match.Domain)
+	default:
+		fmt.Printf("Shortener: %s isn't a shortener domain, its urls are never requested\n", parsedURL.Host)
+	}
+
+	if !ok {
+		return 0
+	}
+
+	resolvedURL, chain, err := resolver.Resolve(rawURL)
+	for i, hop := range chain {
+		fmt.Printf("  %d. %s\n", i+1, hop)
+	}
+	if err != nil {
+		fmt.Printf("Failed to resolve: %v\n", err)
+		fmt.Printf("Using: %s\n", resolvedURL)
+		return 1
+	}
+	fmt.Printf("Resolved: %s\n", resolvedURL)
+	return 0
+}
+
+// currentShortenerRegistry returns the registry set in the config options, waiting
+// for it to be downloaded when it's stale so a first run already knows its domains.
+// It returns nil when no registry is set.
+func currentShortenerRegistry(source string) *shorturl.Registry {
+	if source == "" {
+		return nil
+	}
+
+	source = expandRegistrySource(source)
+	registry := shorturl.NewRegistry(source, shortenerRegistryCachePath(source))
+	if registry.Stale() {
+		if _, err := registry.Refresh(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to refresh shortener registry, using the last download: %v\n", err)
+		}
+	}
+	return registry
+}
+
+// refreshShortenerRegistry updates the shortener registry set in the config
+func refreshShortenerRegistry(options config.Options) int {
+	if options.ShortenerRegistry == "" {
+		fmt.Fprintln(os.Stderr, "No shortenerRegistry set in the config")
+		return 1
+	}
+
+	source := expandRegistrySource(options.ShortenerRegistry)
+	registry := shorturl.NewRegistry(source, shortenerRegistryCachePath(source))
+	count, err := registry.Refresh()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	fmt.Printf("Loaded %d shortener domain(s) from %s\n", count, registry.Source())
+	return 0
+}
@@ -0,0 +1,271 @@
+package shorturl
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultCacheTTL is how long a resolved url is kept when the shortener doesn't say
+	defaultCacheTTL = 24 * time.Hour
+	// maxCacheTTL caps what a shortener can ask for, so changed links are picked up eventually
+	maxCacheTTL = 30 * 24 * time.Hour
+	// failureCacheTTL is how long a url that couldn't be resolved isn't retried
+	failureCacheTTL = 5 * time.Minute
+)
+
+// cacheEntry is where a short url led, or the best url known when resolving it failed
+type cacheEntry struct {
+	URL      string    `json:"url"`
+	Failed   bool      `json:"failed,omitempty"`
+	Chain    []Hop     `json:"chain,omitempty"`
+	Expires  time.Time `json:"expires"`
+	LastUsed time.Time `json:"lastUsed"`
+}
+
+// Cache remembers where short urls lead across runs, so repeated links don't
+// wait on the network and keep working offline
+type Cache struct {
+	path       string
+	maxEntries int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+	// file is the cache file as of the last read or write, nil when there was none,
+	// to notice other processes changing or removing it
+	file os.FileInfo
+}
+
+// NewCache creates a cache persisted to a file, holding at most maxEntries urls
+func NewCache(path string, maxEntries int) *Cache {
+	cache := &Cache{
+		path:       path,
+		maxEntries: maxEntries,
+		now:        time.Now,
+		entries:    make(map[string]*cacheEntry),
+	}
+	cache.load()
+	return cache
+}
+
+// SetMaxEntries changes how many urls the cache holds, dropping the least recently used ones
+func (c *Cache) SetMaxEntries(maxEntries int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.reloadIfChanged()
+	c.maxEntries = maxEntries
+	if c.evict() {
+		c.save()
+	}
+}
+
+// get returns the cached entry for a url if it hasn't expired
+func (c *Cache) get(shortURL string) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.reloadIfChanged()
+	entry, ok := c.entries[shortURL]
+	if !ok {
+		return cacheEntry{}, false
+	}
+	if !c.now().Before(entry.Expires) {
+		delete(c.entries, shortURL)
+		return cacheEntry{}, false
+	}
+
+	entry.LastUsed = c.now()
+	return *entry, true
+}
+
+// set stores where a url led through which hops, or the best url known when resolving failed, for ttl
+func (c *Cache) set(shortURL string, resolvedURL string, chain []Hop, failed bool, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Keeps what other processes wrote, and doesn't bring back a cleared cache
+	c.reloadIfChanged()
+	now := c.now()
+	c.entries[shortURL] = &cacheEntry{
+		URL:      resolvedURL,
+		Failed:   failed,
+		Chain:    chain,
+		Expires:  now.Add(ttl),
+		LastUsed: now,
+	}
+	c.evict()
+	c.save()
+}
+
+// Len returns the number of cached urls
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Clear removes every cached url and returns how many there were
+func (c *Cache) Clear() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	count := len(c.entries)
+	c.entries = make(map[string]*cacheEntry)
+	if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+		return count, err
+	}
+	c.file = nil
+	slog.Debug("Cleared short URL cache", "path", c.path, "count", count)
+	return count, nil
+}
+
+// evict drops expired entries, then the least recently used ones beyond the size
+// bound, and reports whether anything was dropped
+func (c *Cache) evict() bool {
+	now := c.now()
+	evicted := false
+	for key, entry := range c.entries {
+		if !now.Before(entry.Expires) {
+			delete(c.entries, key)
+			evicted = true
+		}
+	}
+
+	if len(c.entries) <= c.maxEntries {
+		return evicted
+	}
+
+	keys := make([]string, 0, len(c.entries))
+	for key := range c.entries {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return c.entries[keys[i]].LastUsed.Before(c.entries[keys[j]].LastUsed)
+	})
+	for _, key := range keys[:len(keys)-max(c.maxEntries, 0)] {
+		delete(c.entries, key)
+	}
+	return true
+}
+
+// reloadIfChanged reads the cache file again when another process, like
+// `finicky cache clear` or another Finicky instance, changed or removed it since
+// it was last read or written
+func (c *Cache) reloadIfChanged() {
+	file, err := c.statFile()
+	if err != nil || sameFile(file, c.file) {
+		return
+	}
+
+	slog.Debug("Short URL cache changed on disk, reloading", "path", c.path)
+	c.entries = make(map[string]*cacheEntry)
+	c.load()
+}
+
+// statFile returns the cache file, nil when there is none
+func (c *Cache) statFile() (os.FileInfo, error) {
+	info, err := os.Stat(c.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return info, err
+}
+
+// sameFile reports whether the cache file is unchanged. Every write replaces the
+// file, so a file changed by another process is a different one.
+func sameFile(a os.FileInfo, b os.FileInfo) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return os.SameFile(a, b) && a.ModTime().Equal(b.ModTime()) && a.Size() == b.Size()
+}
+
+// load reads the cache file, ignoring it if it's missing or unreadable
+func (c *Cache) load() {
+	c.file, _ = c.statFile()
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Debug("Failed to read short URL cache", "path", c.path, "error", err)
+		}
+		return
+	}
+
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		slog.Debug("Failed to parse short URL cache", "path", c.path, "error", err)
+		c.entries = make(map[string]*cacheEntry)
+		return
+	}
+	c.evict()
+}
+
+// save writes the cache file, so a crash never leaves it half written
+func (c *Cache) save() {
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		slog.Debug("Failed to marshal short URL cache", "error", err)
+		return
+	}
+
+	if err := writeFileAtomic(c.path, data); err != nil {
+		slog.Debug("Failed to write short URL cache", "path", c.path, "error", err)
+		return
+	}
+	c.file, _ = c.statFile()
+}
+
+// writeFileAtomic writes a file through a temporary file, so readers never see it half written
+func writeFileAtomic(path string, data []byte) error {
+	tempFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	_, writeErr := tempFile.Write(data)
+	closeErr := tempFile.Close()
+	if writeErr != nil || closeErr != nil {
+		os.Remove(tempFile.Name())
+		return errors.Join(writeErr, closeErr)
+	}
+
+	if err := os.Rename(tempFile.Name(), path); err != nil {
+		os.Remove(tempFile.Name())
+		return err
+	}
+	return nil
+}
+
+// cacheTTL returns how long a response from a shortener may be cached according
+// to its Cache-Control header
+func cacheTTL(header http.Header) time.Duration {
+	ttl := defaultCacheTTL
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store", "no-cache":
+			return 0
+		case "max-age":
+			seconds, err := strconv.Atoi(strings.Trim(value, `"`))
+			if err != nil {
+				continue
+			}
+			ttl = time.Duration(seconds) * time.Second
+		}
+	}
+	return min(ttl, maxCacheTTL)
+}
@@ -0,0 +1,138 @@
+package shorturl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheTTL(t *testing.T) {
+	tests := []struct {
+		cacheControl string
+		want         time.Duration
+	}{
+		{"", defaultCacheTTL},
+		{"public, max-age=600", 10 * time.Minute},
+		{"max-age=0", 0},
+		{"private, no-store", 0},
+		{"no-cache", 0},
+		{"max-age=999999999", maxCacheTTL},
+		{"max-age=soon", defaultCacheTTL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cacheControl, func(t *testing.T) {
+			header := http.Header{}
+			header.Set("Cache-Control", tt.cacheControl)
+			if got := cacheTTL(header); got != tt.want {
+				t.Errorf("cacheTTL(%q) = %v, want %v", tt.cacheControl, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveWithCache(t *testing.T) {
+	requests := 0
+	shortener := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/uncacheable":
+			w.Header().Set("Cache-Control", "no-store")
+			http.Redirect(w, r, "/landing", http.StatusFound)
+		case "/landing":
+		default:
+			w.Header().Set("Cache-Control", "max-age=60")
+			http.Redirect(w, r, "/landing", http.StatusFound)
+		}
+	}))
+	defer shortener.Close()
+
+	shortenerHost, _ := url.Parse(shortener.URL)
+	now := time.Now()
+	cachePath := filepath.Join(t.TempDir(), "short_urls.json")
+
+	newResolver := func(maxEntries int) *Resolver {
+		cache := NewCache(cachePath, maxEntries)
+		cache.now = func() time.Time { return now }
+		resolver := NewResolver()
+		resolver.Methods = []string{http.MethodGet}
+		resolver.ExtraDomains = []string{shortenerHost.Host}
+		resolver.Cache = cache
+		return resolver
+	}
+
+	tests := []struct {
+		name         string
+		url          string
+		advance      time.Duration
+		maxEntries   int
+		wantRequests int
+		wantErr      bool
+	}{
+		{name: "resolves over the network first", url: "/a", wantRequests: 2},
+		{name: "uses the cache across runs", url: "/a", wantRequests: 0},
+		{name: "resolves again after max-age", url: "/a", advance: 2 * time.Minute, wantRequests: 2},
+		{name: "doesn't cache no-store responses", url: "/uncacheable", wantRequests: 2},
+		{name: "resolves no-store urls every time", url: "/uncacheable", wantRequests: 2},
+		{name: "remembers failures", url: "/broken", wantRequests: 1, wantErr: true},
+		{name: "doesn't retry failures right away", url: "/broken", wantRequests: 0, wantErr: true},
+		{name: "retries failures later", url: "/broken", advance: 10 * time.Minute, wantRequests: 1, wantErr: true},
+		{name: "drops the least recently used urls", url: "/b", maxEntries: 1, wantRequests: 2},
+		{name: "dropped urls are resolved again", url: "/a", maxEntries: 1, wantRequests: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = now.Add(tt.advance)
+			requests = 0
+			maxEntries := tt.maxEntries
+			if maxEntries == 0 {
+				maxEntries = 100
+			}
+
+			got, _, err := newResolver(maxEntries).Resolve(shortener.URL + tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Resolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != shortener.URL+"/landing" {
+				t.Errorf("Resolve() = %q, want the landing page", got)
+			}
+			if requests != tt.wantRequests {
+				t.Errorf("Resolve() made %d requests, want %d", requests, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestCacheSharedFile(t *testing.T) {
+	cachePath := filepath.Join(t.TempDir(), "short_urls.json")
+	running := NewCache(cachePath, 100)
+	other := NewCache(cachePath, 100)
+
+	running.set("https://bit.ly/a", "https://example.com/a", nil, false, time.Hour)
+	other.set("https://bit.ly/b", "https://example.com/b", nil, false, time.Hour)
+	if _, ok := running.get("https://bit.ly/b"); !ok {
+		t.Error("url cached by another process isn't found")
+	}
+	if _, ok := NewCache(cachePath, 100).get("https://bit.ly/a"); !ok {
+		t.Error("url cached before another process wrote the file was lost")
+	}
+
+	// Like `finicky cache clear` while Finicky is running
+	if count, err := NewCache(cachePath, 100).Clear(); err != nil || count != 2 {
+		t.Fatalf("Clear() = %d, %v, want 2 urls cleared", count, err)
+	}
+	if _, ok := running.get("https://bit.ly/a"); ok {
+		t.Error("url is still cached after clearing the cache file")
+	}
+
+	running.set("https://bit.ly/c", "https://example.com/c", nil, false, time.Hour)
+	if count := NewCache(cachePath, 100).Len(); count != 1 {
+		t.Errorf("cache file has %d urls after caching one more, want 1", count)
+	}
+}
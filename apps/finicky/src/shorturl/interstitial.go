@@ -0,0 +1,119 @@
+package shorturl
+
+import (
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// maxInterstitialBytes is how much of a page is read looking for a redirect,
+// interstitials put it in the head so the rest of the page is never needed
+const maxInterstitialBytes = 64 << 10
+
+var (
+	metaTagPattern   = regexp.MustCompile(`(?is)<meta\b[^>]*>`)
+	anchorTagPattern = regexp.MustCompile(`(?is)<a\b[^>]*>`)
+	scriptPattern    = regexp.MustCompile(`(?is)<script\b[^>]*>(.*?)</script>`)
+	attributePattern = regexp.MustCompile(`(?s)([a-zA-Z_:][-a-zA-Z0-9_:.]*)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	refreshPattern   = regexp.MustCompile(`(?is)^\s*\d*(?:\.\d*)?\s*[;,]\s*url\s*=\s*['"]?([^'"]+)['"]?\s*$`)
+
+	// scriptPatterns match the ways interstitial pages send the browser on with
+	// JavaScript, and are only looked for inside script elements
+	scriptPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`\b(?:window|document|top|self)\.location(?:\.href)?\s*=\s*["']([^"']+)["']`),
+		regexp.MustCompile(`\b(?:window|document|top|self)\.location\.(?:replace|assign)\(\s*["']([^"']+)["']\s*\)`),
+	}
+)
+
+// interstitialLinks are attributes of links that known interstitial pages ask
+// the visitor to click, like the "continue to site" link of lnkd.in
+var interstitialLinks = map[string]string{
+	"data-tracking-control-name": "external_url_click",
+}
+
+// findInterstitialRedirect reads the start of an HTML page and returns the url
+// it sends the browser to with a meta refresh, a script or a known interstitial
+// link, or an empty string when it doesn't
+func findInterstitialRedirect(resp *http.Response) string {
+	if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil && mediaType != "text/html" {
+		return ""
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxInterstitialBytes))
+	if err != nil && len(body) == 0 {
+		return ""
+	}
+	page := string(body)
+
+	for _, candidate := range interstitialCandidates(page) {
+		if target := absoluteURL(resp.Request.URL, candidate); target != "" && target != resp.Request.URL.String() {
+			return target
+		}
+	}
+	return ""
+}
+
+// interstitialCandidates returns the urls a page may redirect to, in the order they're trusted
+func interstitialCandidates(page string) []string {
+	var candidates []string
+
+	for _, tag := range metaTagPattern.FindAllString(page, -1) {
+		attributes := parseAttributes(tag)
+		if !strings.EqualFold(attributes["http-equiv"], "refresh") {
+			continue
+		}
+		if match := refreshPattern.FindStringSubmatch(attributes["content"]); match != nil {
+			candidates = append(candidates, match[1])
+		}
+	}
+
+	for _, script := range scriptPattern.FindAllStringSubmatch(page, -1) {
+		for _, pattern := range scriptPatterns {
+			for _, match := range pattern.FindAllStringSubmatch(script[1], -1) {
+				candidates = append(candidates, strings.ReplaceAll(match[1], `\/`, "/"))
+			}
+		}
+	}
+
+	for _, tag := range anchorTagPattern.FindAllString(page, -1) {
+		attributes := parseAttributes(tag)
+		for name, value := range interstitialLinks {
+			if attributes[name] == value && attributes["href"] != "" {
+				candidates = append(candidates, attributes["href"])
+			}
+		}
+	}
+
+	return candidates
+}
+
+// parseAttributes returns the attributes of an HTML tag by lowercase name, with entities decoded
+func parseAttributes(tag string) map[string]string {
+	attributes := make(map[string]string)
+	for _, match := range attributePattern.FindAllStringSubmatch(tag, -1) {
+		name := strings.ToLower(match[1])
+		if _, ok := attributes[name]; ok {
+			continue
+		}
+		attributes[name] = html.UnescapeString(match[2] + match[3] + match[4])
+	}
+	return attributes
+}
+
+// absoluteURL resolves a url found in a page against the page's url, returning
+// an empty string unless it's an http or https url
+func absoluteURL(base *url.URL, rawURL string) string {
+	reference, err := url.Parse(strings.TrimSpace(html.UnescapeString(rawURL)))
+	if err != nil {
+		return ""
+	}
+	resolved := base.ResolveReference(reference)
+	if resolved.Scheme != "http" && resolved.Scheme != "https" {
+		return ""
+	}
+	return resolved.String()
+}
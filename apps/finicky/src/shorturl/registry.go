@@ -0,0 +1,166 @@
+package shorturl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// registryRefreshInterval is how often a registry at a url is downloaded again
+	registryRefreshInterval = 24 * time.Hour
+	// maxRegistryBytes bounds the size of a downloaded registry
+	maxRegistryBytes = 1 << 20
+)
+
+// Registry is a list of shortener domains kept outside the app, in a local file
+// or at a url, so it can be updated without a new release. It uses the same JSON
+// format as the built-in list.
+type Registry struct {
+	source string
+	// cachePath is where a registry at a url is kept between downloads
+	cachePath string
+	client    *http.Client
+
+	mu      sync.RWMutex
+	domains []string
+}
+
+// NewRegistry creates a registry read from source, a file path or an http(s) url
+// whose last download is kept at cachePath
+func NewRegistry(source string, cachePath string) *Registry {
+	registry := &Registry{
+		source:    source,
+		cachePath: cachePath,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+	if err := registry.load(); err != nil && !os.IsNotExist(err) {
+		slog.Warn("Failed to load shortener registry", "source", source, "error", err)
+	}
+	return registry
+}
+
+// Source returns the file path or url the registry is read from
+func (r *Registry) Source() string {
+	return r.source
+}
+
+// Domains returns the shortener domains in the registry
+func (r *Registry) Domains() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.domains
+}
+
+func (r *Registry) isRemote() bool {
+	return strings.HasPrefix(r.source, "http://") || strings.HasPrefix(r.source, "https://")
+}
+
+// localPath returns the file the domains are read from
+func (r *Registry) localPath() string {
+	if r.isRemote() {
+		return r.cachePath
+	}
+	return r.source
+}
+
+// load reads the domains from the local file
+func (r *Registry) load() error {
+	data, err := os.ReadFile(r.localPath())
+	if err != nil {
+		return err
+	}
+
+	domains, err := parseRegistry(data)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.domains = domains
+	r.mu.Unlock()
+	return nil
+}
+
+// Stale reports whether a registry at a url hasn't been downloaded recently
+func (r *Registry) Stale() bool {
+	if !r.isRemote() {
+		return false
+	}
+	info, err := os.Stat(r.cachePath)
+	if err != nil {
+		return true
+	}
+	return time.Since(info.ModTime()) > registryRefreshInterval
+}
+
+// Refresh downloads a registry at a url, or reads a local one again, and returns
+// how many domains it has. The previous domains are kept when it fails.
+func (r *Registry) Refresh() (int, error) {
+	if r.isRemote() {
+		if err := r.download(); err != nil {
+			return len(r.Domains()), err
+		}
+	}
+
+	if err := r.load(); err != nil {
+		return len(r.Domains()), fmt.Errorf("failed to read shortener registry: %v", err)
+	}
+
+	slog.Debug("Refreshed shortener registry", "source", r.source, "domains", len(r.Domains()))
+	return len(r.Domains()), nil
+}
+
+// download saves the registry at the source url to the cache path
+func (r *Registry) download() error {
+	resp, err := r.client.Get(r.source)
+	if err != nil {
+		return fmt.Errorf("failed to download shortener registry: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download shortener registry: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRegistryBytes))
+	if err != nil {
+		return fmt.Errorf("failed to download shortener registry: %v", err)
+	}
+
+	// A broken download must not replace the last good one
+	if _, err := parseRegistry(data); err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(r.cachePath, data); err != nil {
+		return fmt.Errorf("failed to save shortener registry: %v", err)
+	}
+	return nil
+}
+
+// parseRegistry parses a JSON list of domains. Domains without at least two labels
+// are skipped, as they would send every url on a top level domain to the network.
+func parseRegistry(data []byte) ([]string, error) {
+	var entries []string
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid shortener registry: %v", err)
+	}
+
+	domains := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		domain := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(entry)), ".")
+		if !strings.Contains(strings.Trim(domain, "."), ".") {
+			slog.Debug("Skipping shortener registry entry", "entry", entry)
+			continue
+		}
+		domains = append(domains, domain)
+	}
+	return domains, nil
+}
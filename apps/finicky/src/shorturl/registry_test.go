@@ -0,0 +1,76 @@
+package shorturl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	body := `["go.example.com", "LNK.Example.org.", "com", ""]`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	localPath := filepath.Join(dir, "local.json")
+	if err := os.WriteFile(localPath, []byte(`["links.example.net"]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		source      string
+		body        string
+		wantDomains []string
+		wantErr     bool
+	}{
+		{
+			name:        "downloads a registry at a url",
+			source:      server.URL,
+			body:        body,
+			wantDomains: []string{"go.example.com", "lnk.example.org"},
+		},
+		{
+			name:        "keeps the last download when the registry is broken",
+			source:      server.URL,
+			body:        `{"not": "a list"}`,
+			wantDomains: []string{"go.example.com", "lnk.example.org"},
+			wantErr:     true,
+		},
+		{
+			name:        "reads a local registry",
+			source:      localPath,
+			wantDomains: []string{"links.example.net"},
+		},
+	}
+
+	cachePath := filepath.Join(dir, "registry.json")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body = tt.body
+			registry := NewRegistry(tt.source, cachePath)
+
+			_, err := registry.Refresh()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Refresh() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := registry.Domains(); !slices.Equal(got, tt.wantDomains) {
+				t.Errorf("Domains() = %v, want %v", got, tt.wantDomains)
+			}
+			if registry.Stale() {
+				t.Errorf("Stale() = true right after refreshing")
+			}
+		})
+	}
+
+	resolver := NewResolver()
+	resolver.Registry = NewRegistry(server.URL, cachePath)
+	if match, ok := resolver.MatchShortener("www.go.example.com"); !ok || match.Source != SourceRegistry {
+		t.Errorf("MatchShortener() = %+v, %v, want a registry match", match, ok)
+	}
+}
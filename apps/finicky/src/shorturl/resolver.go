@@ -1,10 +1,12 @@
 package shorturl
 
 import (
+	"context"
 	"embed"
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
@@ -34,30 +36,267 @@ func init() {
 	}
 }
 
-// ResolveURL resolves a potentially shortened URL to its final destination by following HTTP redirects, so
-// the matcher can match the final URL instead of the short URL.
-func ResolveURL(originalURL string) (string, error) {
-	parsedURL, err := url.Parse(originalURL)
-	if err != nil {
-		return originalURL, fmt.Errorf("failed to parse URL: %v", err)
+// Hop is one url a link passed through on its way to its destination
+type Hop struct {
+	URL string `json:"url"`
+	// Status is the HTTP status the url answered with, zero when it wasn't requested
+	Status int    `json:"status"`
+	Host   string `json:"host"`
+}
+
+// String formats a hop for logs
+func (h Hop) String() string {
+	if h.Status == 0 {
+		return h.URL
+	}
+	return fmt.Sprintf("%s (%d)", h.URL, h.Status)
+}
+
+// newHop creates a hop for a url
+func newHop(rawURL string, status int) Hop {
+	hop := Hop{URL: rawURL, Status: status}
+	if parsedURL, err := url.Parse(rawURL); err == nil {
+		hop.Host = parsedURL.Hostname()
+	}
+	return hop
+}
+
+// appendHop adds a hop to a chain, merging it with the last hop when it's the
+// same url so a url both unwrapped and requested is only listed once
+func appendHop(chain []Hop, hop Hop) []Hop {
+	if len(chain) > 0 && chain[len(chain)-1].URL == hop.URL {
+		if hop.Status != 0 {
+			chain[len(chain)-1].Status = hop.Status
+		}
+		return chain
+	}
+	return append(chain, hop)
+}
+
+// AppendChain adds the hops of another chain to a chain, merging a url the first
+// ends with and the second starts with
+func AppendChain(chain []Hop, hops []Hop) []Hop {
+	for _, hop := range hops {
+		chain = appendHop(chain, hop)
+	}
+	return chain
+}
+
+// Resolver follows the redirects of short urls to find where they lead, so
+// the matcher can match the final URL instead of the short URL
+type Resolver struct {
+	// Timeout limits how long resolving a url can take, across all methods and redirects,
+	// zero for no limit
+	Timeout time.Duration
+	// MaxRedirects is the number of redirects followed before giving up
+	MaxRedirects int
+	// Methods are the HTTP methods tried in order until one gets a successful response
+	Methods []string
+	// UserAgent is sent with every request unless the headers of a domain override it
+	UserAgent string
+	// Headers are extra request headers by shortener domain, with "*" applying to
+	// every shortener. They're never sent to the sites shorteners redirect to.
+	Headers map[string]map[string]string
+	// ExtraDomains are resolved in addition to the built-in shortener domains
+	ExtraDomains []string
+	// DisabledDomains are never resolved, even when they are built-in shortener domains
+	DisabledDomains []string
+	// Registry is an external list of shortener domains resolved in addition to the built-in ones
+	Registry *Registry
+	// Cache keeps resolved urls between runs, nil to always resolve over the network
+	Cache *Cache
+}
+
+// NewResolver returns a resolver with the default policy
+func NewResolver() *Resolver {
+	return &Resolver{
+		Timeout:      750 * time.Millisecond,
+		MaxRedirects: 3,
+		Methods:      []string{http.MethodHead, http.MethodGet},
+		UserAgent:    "Finicky/4.0",
+	}
+}
+
+// matchesDomain reports whether a host is a domain or one of its subdomains,
+// comparing whole labels so notbit.ly doesn't match bit.ly. The port of the host
+// is only compared when the domain has one.
+func matchesDomain(host string, domain string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+	if domain == "" {
+		return false
+	}
+
+	if !strings.Contains(domain, ":") {
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			host = hostname
+		}
+	}
+
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// Where a shortener domain comes from
+const (
+	SourceBuiltIn  = "built-in"
+	SourceConfig   = "config"
+	SourceRegistry = "registry"
+	SourceDisabled = "disabled"
+)
+
+// domainList is a list of shortener domains from one source
+type domainList struct {
+	source  string
+	domains []string
+}
+
+// ShortenerMatch is the shortener domain a host matched and where it comes from
+type ShortenerMatch struct {
+	Domain string
+	Source string
+}
+
+// MatchShortener returns the shortener domain matching a host. A host matching a
+// disabled domain returns that match with false, as its urls aren't resolved.
+func (r *Resolver) MatchShortener(host string) (ShortenerMatch, bool) {
+	for _, domain := range r.DisabledDomains {
+		if matchesDomain(host, domain) {
+			return ShortenerMatch{Domain: domain, Source: SourceDisabled}, false
+		}
+	}
+
+	lists := []domainList{{SourceConfig, r.ExtraDomains}, {SourceBuiltIn, shortenerDomains}}
+	if r.Registry != nil {
+		lists = append(lists, domainList{SourceRegistry, r.Registry.Domains()})
+	}
+
+	for _, list := range lists {
+		for _, domain := range list.domains {
+			if matchesDomain(host, domain) {
+				return ShortenerMatch{Domain: domain, Source: list.source}, true
+			}
+		}
+	}
+
+	return ShortenerMatch{}, false
+}
+
+// IsShortURL reports whether urls on a host are resolved
+func (r *Resolver) IsShortURL(host string) bool {
+	_, ok := r.MatchShortener(host)
+	return ok
+}
+
+// setHeaders replaces the headers of a request with the ones configured for its
+// host. Hosts that aren't shorteners only get the user agent.
+func (r *Resolver) setHeaders(req *http.Request) {
+	req.Header = http.Header{}
+	req.Header.Set("User-Agent", r.UserAgent)
+	if !r.IsShortURL(req.URL.Host) {
+		return
+	}
+
+	setAll := func(headers map[string]string) {
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
 	}
 
-	// Check if the domain is a known URL shortener
-	isShortURL := false
-	for _, domain := range shortenerDomains {
-		if strings.HasSuffix(parsedURL.Host, domain) {
-			isShortURL = true
+	// Headers for every domain come first so a domain can override them
+	setAll(r.Headers["*"])
+	for domain, headers := range r.Headers {
+		if domain != "*" && matchesDomain(req.URL.Hostname(), domain) {
+			setAll(headers)
+		}
+	}
+}
+
+// Resolve finds the final destination of a url. Known redirect wrappers are
+// unwrapped first without any network, then short urls are resolved by following
+// their HTTP redirects and the redirects of their interstitial pages.
+// It also returns the chain of urls passed through, from the original url to
+// the final one.
+func (r *Resolver) Resolve(originalURL string) (string, []Hop, error) {
+	var chain []Hop
+	addHops := func(hops []Hop) {
+		for _, hop := range hops {
+			chain = appendHop(chain, hop)
+		}
+	}
+
+	unwrappedURL, hops := unwrapAndLog(originalURL)
+	addHops(hops)
+
+	resolvedURL, hops, err := r.resolveShortURL(unwrappedURL)
+	addHops(hops)
+
+	// Shorteners sometimes lead to a wrapped link
+	destination, hops := unwrapAndLog(resolvedURL)
+	addHops(hops)
+
+	return destination, chain, err
+}
+
+// unwrapAndLog unwraps a url, logging the wrappers it was in, and returns the
+// hops from the url to its destination
+func unwrapAndLog(rawURL string) (string, []Hop) {
+	hops := []Hop{newHop(rawURL, 0)}
+	for len(hops) <= maxUnwrapDepth {
+		destination, name, ok := unwrapOnce(rawURL)
+		if !ok {
 			break
 		}
+		slog.Debug("Unwrapped redirect wrapper", "url", rawURL, "wrapper", name, "destination", destination)
+		rawURL = destination
+		hops = append(hops, newHop(rawURL, 0))
+	}
+	return rawURL, hops
+}
+
+// resolveShortURL resolves a url on a shortener domain, using the cache when there
+// is one, and returns the hops it took
+func (r *Resolver) resolveShortURL(originalURL string) (string, []Hop, error) {
+	parsedURL, err := url.Parse(originalURL)
+	if err != nil {
+		return originalURL, nil, fmt.Errorf("failed to parse URL: %v", err)
 	}
 
-	if !isShortURL {
-		return originalURL, nil
+	if !r.IsShortURL(parsedURL.Host) {
+		return originalURL, nil, nil
 	}
 
 	slog.Debug("URL host looks like a short URL", "host", parsedURL.Host)
 
+	if r.Cache == nil {
+		resolvedURL, chain, _, err := r.resolve(originalURL)
+		return resolvedURL, chain, err
+	}
+
+	if entry, ok := r.Cache.get(originalURL); ok {
+		if entry.Failed {
+			return entry.URL, entry.Chain, fmt.Errorf("resolving failed recently, retrying after %s", entry.Expires.Format(time.Kitchen))
+		}
+		slog.Debug("Using cached short URL", "url", originalURL, "resolved", entry.URL)
+		return entry.URL, entry.Chain, nil
+	}
+
+	resolvedURL, chain, ttl, err := r.resolve(originalURL)
+	if err != nil {
+		r.Cache.set(originalURL, resolvedURL, chain, true, failureCacheTTL)
+	} else {
+		r.Cache.set(originalURL, resolvedURL, chain, false, ttl)
+	}
+	return resolvedURL, chain, err
+}
+
+// resolve follows the redirects of a short url over the network, including the
+// ones of interstitial pages on shortener domains, and returns where it led through
+// which hops with how long the shortener allows caching that
+func (r *Resolver) resolve(originalURL string) (string, []Hop, time.Duration, error) {
 	var lastUrl string
+	var ttl time.Duration
+	var redirects int
+	var chain []Hop
 
 	// Helper to get the best available URL
 	getReturnUrl := func() string {
@@ -68,70 +307,97 @@ func ResolveURL(originalURL string) (string, error) {
 		return originalURL
 	}
 
-	// Create a client with a timeout
+	ctx := context.Background()
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+
 	client := &http.Client{
-		Timeout: 750 * time.Millisecond,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			// The shortener's own redirect decides how long the result can be cached
+			if redirects == 0 && req.Response != nil {
+				ttl = cacheTTL(req.Response.Header)
+			}
+			if req.Response != nil {
+				chain = append(chain, newHop(req.Response.Request.URL.String(), req.Response.StatusCode))
+			}
 			lastUrl = req.URL.String()
 			slog.Debug("Redirected to", "url", lastUrl)
-			// Allow up to 3 redirects
-			if len(via) >= 3 {
-				return fmt.Errorf("stopped after 3 redirects")
+			redirects++
+			if redirects > r.MaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", r.MaxRedirects)
 			}
+			// Headers configured for the shortener must not leak to the sites it redirects to
+			r.setHeaders(req)
 			return nil
 		},
 	}
 
-	// Make a HEAD request first to follow redirects without downloading content
-	req, err := http.NewRequest("HEAD", originalURL, nil)
-	if err != nil {
-		return originalURL, fmt.Errorf("failed to create request: %v", err)
-	}
-	req.Header.Set("User-Agent", "Finicky/4.0")
+	for i, method := range r.Methods {
+		redirects = 0
+		chain = nil
+		target := originalURL
 
-	resp, err := client.Do(req)
+		for {
+			req, err := http.NewRequestWithContext(ctx, method, target, nil)
+			if err != nil {
+				return getReturnUrl(), chain, 0, fmt.Errorf("failed to create %s request: %v", method, err)
+			}
+			r.setHeaders(req)
 
-	if err != nil {
-		slog.Debug("Failed to make HEAD request", "url", originalURL, "error", err)
-	}
+			resp, err := client.Do(req)
+			if err != nil {
+				slog.Debug("Failed to make request", "method", method, "url", target, "error", err)
+				break
+			}
 
-	if resp != nil {
-		// If we got a successful response, return the final URL
-		if resp.StatusCode == http.StatusOK {
-			slog.Debug("Got a successful response", "url", resp.Request.URL.String())
-			return resp.Request.URL.String(), nil
-		}
+			finalURL := resp.Request.URL.String()
+			chain = append(chain, newHop(finalURL, resp.StatusCode))
+			onShortener := r.IsShortURL(resp.Request.URL.Host)
 
-		if resp.Body != nil {
+			// Some shorteners answer with a page that redirects instead of an HTTP redirect
+			var next string
+			if resp.StatusCode == http.StatusOK && method == http.MethodGet && onShortener {
+				next = findInterstitialRedirect(resp)
+			}
 			resp.Body.Close()
-		}
-	}
-
-	// If HEAD request failed, try GET as fallback
-	req, err = http.NewRequest("GET", originalURL, nil)
-	if err != nil {
-		return getReturnUrl(), fmt.Errorf("failed to create GET request: %v", err)
-	}
-	req.Header.Set("User-Agent", "Finicky/4.0")
 
-	resp, err = client.Do(req)
+			if resp.StatusCode != http.StatusOK {
+				break
+			}
+			if finalURL == originalURL {
+				ttl = cacheTTL(resp.Header)
+			}
 
-	if err != nil {
-		return getReturnUrl(), fmt.Errorf("failed to make GET request: %v", err)
-	}
+			if next != "" {
+				lastUrl = next
+				redirects++
+				slog.Debug("Interstitial page redirected to", "url", next)
+				// Wrapped links are unwrapped without requesting them
+				if _, _, ok := unwrapOnce(next); ok {
+					return next, chain, ttl, nil
+				}
+				if redirects > r.MaxRedirects {
+					return getReturnUrl(), chain, 0, fmt.Errorf("failed to resolve URL: stopped after %d redirects", r.MaxRedirects)
+				}
+				target = next
+				continue
+			}
 
-	if resp != nil {
-		if resp.StatusCode == http.StatusOK {
-			slog.Debug("Got a successful response", "url", resp.Request.URL.String())
-			return resp.Request.URL.String(), nil
-		}
+			// Pages of a shortener can only be read with a later method
+			if method == http.MethodHead && onShortener && i < len(r.Methods)-1 {
+				slog.Debug("Response is still on a shortener, trying the next method", "url", finalURL)
+				break
+			}
 
-		if resp.Body != nil {
-			resp.Body.Close()
+			// If we got a successful response, return the final URL
+			slog.Debug("Got a successful response", "method", method, "url", finalURL)
+			return finalURL, chain, ttl, nil
 		}
 	}
 
-	// If both HEAD and GET failed, return original URL
-	return getReturnUrl(), fmt.Errorf("failed to resolve URL: no response received")
-
+	// If every method failed, return the best URL we know of
+	return getReturnUrl(), chain, 0, fmt.Errorf("failed to resolve URL: no successful response received")
 }
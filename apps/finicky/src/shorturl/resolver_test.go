@@ -0,0 +1,257 @@
+package shorturl
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolve(t *testing.T) {
+	var targetHeader string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		targetHeader = r.Header.Get("X-Token")
+	}))
+	defer target.Close()
+	// A different host name than the shortener's, so only the shortener gets its headers
+	targetURL := strings.Replace(target.URL, "127.0.0.1", "localhost", 1)
+
+	shortener := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Header.Get("X-Token") != "secret":
+			w.WriteHeader(http.StatusForbidden)
+		case r.URL.Path == "/slow":
+			time.Sleep(200 * time.Millisecond)
+		case r.URL.Path == "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case r.Method == http.MethodHead && r.URL.Path == "/get-only":
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case r.URL.Path == "/meta-refresh":
+			fmt.Fprintf(w, `<html><head><meta http-equiv="Refresh" content="0; url='%s/landing?a=1&amp;b=2'"></head></html>`, targetURL)
+		case r.URL.Path == "/script":
+			fmt.Fprintf(w, `<script>window.location.replace("%s\/landing");</script>`, strings.ReplaceAll(targetURL, "/", `\/`))
+		case r.URL.Path == "/interstitial":
+			fmt.Fprintf(w, `<a data-tracking-control-name="external_url_click" href="%s/landing">Continue</a>`, targetURL)
+		case r.URL.Path == "/chained":
+			fmt.Fprint(w, `<html><meta http-equiv="refresh" content="0;url=/meta-refresh"></html>`)
+		case r.URL.Path == "/refresh-loop":
+			fmt.Fprintf(w, `<html><meta http-equiv="refresh" content="0;url=/refresh-loop?%sx"></html>`, r.URL.RawQuery)
+		case r.URL.Path == "/location-attribute":
+			fmt.Fprint(w, `<html><body><div data-location="https://maps.example.com/x">Link not found</div></body></html>`)
+		case r.URL.Path == "/canonical":
+			fmt.Fprint(w, `<html><head><link rel="canonical" href="/"></head><body>Link not found</body></html>`)
+		case r.URL.Path == "/plain":
+			fmt.Fprint(w, `<html><body>No redirect here</body></html>`)
+		default:
+			http.Redirect(w, r, targetURL+"/landing", http.StatusMovedPermanently)
+		}
+	}))
+	defer shortener.Close()
+
+	shortenerHost, _ := url.Parse(shortener.URL)
+
+	newResolver := func() *Resolver {
+		resolver := NewResolver()
+		resolver.Timeout = 100 * time.Millisecond
+		resolver.ExtraDomains = []string{shortenerHost.Host}
+		resolver.Headers = map[string]map[string]string{
+			shortenerHost.Hostname(): {"X-Token": "secret"},
+		}
+		return resolver
+	}
+
+	tests := []struct {
+		name    string
+		url     string
+		setup   func(resolver *Resolver)
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "follows redirects to the target",
+			url:  shortener.URL + "/abc",
+			want: targetURL + "/landing",
+		},
+		{
+			name: "falls back to the next method",
+			url:  shortener.URL + "/get-only",
+			want: targetURL + "/landing",
+		},
+		{
+			name: "follows meta refresh pages",
+			url:  shortener.URL + "/meta-refresh",
+			want: targetURL + "/landing?a=1&b=2",
+		},
+		{
+			name: "follows script redirects",
+			url:  shortener.URL + "/script",
+			want: targetURL + "/landing",
+		},
+		{
+			name: "follows known interstitial links",
+			url:  shortener.URL + "/interstitial",
+			want: targetURL + "/landing",
+		},
+		{
+			name: "follows interstitial pages leading to other interstitial pages",
+			url:  shortener.URL + "/chained",
+			want: targetURL + "/landing?a=1&b=2",
+		},
+		{
+			name:    "stops after the maximum number of interstitial pages",
+			url:     shortener.URL + "/refresh-loop",
+			want:    shortener.URL + "/refresh-loop?xxxx",
+			wantErr: true,
+		},
+		{
+			name: "keeps pages without a redirect",
+			url:  shortener.URL + "/plain",
+			want: shortener.URL + "/plain",
+		},
+		{
+			name: "ignores location outside of scripts",
+			url:  shortener.URL + "/location-attribute",
+			want: shortener.URL + "/location-attribute",
+		},
+		{
+			name: "ignores canonical links of not found pages",
+			url:  shortener.URL + "/canonical",
+			want: shortener.URL + "/canonical",
+		},
+		{
+			name:    "gives up after the timeout",
+			url:     shortener.URL + "/slow",
+			want:    shortener.URL + "/slow",
+			wantErr: true,
+		},
+		{
+			name: "resolves without a time limit",
+			url:  shortener.URL + "/abc",
+			setup: func(resolver *Resolver) {
+				resolver.Timeout = 0
+			},
+			want: targetURL + "/landing",
+		},
+		{
+			name: "sends headers for every domain only to shorteners",
+			url:  shortener.URL + "/abc",
+			setup: func(resolver *Resolver) {
+				resolver.Headers = map[string]map[string]string{"*": {"X-Token": "secret"}}
+			},
+			want: targetURL + "/landing",
+		},
+		{
+			name:    "stops after the maximum number of redirects",
+			url:     shortener.URL + "/loop",
+			want:    shortener.URL + "/loop",
+			wantErr: true,
+		},
+		{
+			name: "skips disabled domains",
+			url:  shortener.URL + "/abc",
+			setup: func(resolver *Resolver) {
+				resolver.DisabledDomains = []string{shortenerHost.Host}
+			},
+			want: shortener.URL + "/abc",
+		},
+		{
+			name: "skips other domains",
+			url:  "https://example.com/abc",
+			want: "https://example.com/abc",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targetHeader = ""
+			resolver := newResolver()
+			if tt.setup != nil {
+				tt.setup(resolver)
+			}
+
+			got, _, err := resolver.Resolve(tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Resolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Resolve() = %q, want %q", got, tt.want)
+			}
+			if targetHeader != "" {
+				t.Errorf("header for the shortener was sent to the target")
+			}
+		})
+	}
+}
+
+func TestResolveChain(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	targetURL := strings.Replace(target.URL, "127.0.0.1", "localhost", 1)
+	wrappedURL := "https://www.google.com/url?q=" + url.QueryEscape(targetURL+"/landing")
+
+	shortener := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/start":
+			http.Redirect(w, r, "/page", http.StatusFound)
+		case "/page":
+			fmt.Fprintf(w, `<html><meta http-equiv="refresh" content="0;url=%s"></html>`, wrappedURL)
+		}
+	}))
+	defer shortener.Close()
+
+	shortenerHost, _ := url.Parse(shortener.URL)
+	resolver := NewResolver()
+	resolver.Timeout = 100 * time.Millisecond
+	resolver.Methods = []string{http.MethodGet}
+	resolver.ExtraDomains = []string{shortenerHost.Host}
+
+	got, chain, err := resolver.Resolve(shortener.URL + "/start")
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if got != targetURL+"/landing" {
+		t.Errorf("Resolve() = %q, want the landing page", got)
+	}
+
+	want := []Hop{
+		{URL: shortener.URL + "/start", Status: http.StatusFound, Host: "127.0.0.1"},
+		{URL: shortener.URL + "/page", Status: http.StatusOK, Host: "127.0.0.1"},
+		{URL: wrappedURL, Status: 0, Host: "www.google.com"},
+		{URL: targetURL + "/landing", Status: 0, Host: "localhost"},
+	}
+	if !slices.Equal(chain, want) {
+		t.Errorf("Resolve() chain = %+v, want %+v", chain, want)
+	}
+}
+
+func TestMatchesDomain(t *testing.T) {
+	tests := []struct {
+		host   string
+		domain string
+		want   bool
+	}{
+		{"bit.ly", "bit.ly", true},
+		{"www.bit.ly", "bit.ly", true},
+		{"BIT.LY", "bit.ly", true},
+		{"bit.ly.", "bit.ly", true},
+		{"bit.ly:443", "bit.ly", true},
+		{"notbit.ly", "bit.ly", false},
+		{"evilt.co", "t.co", false},
+		{"bit.ly.example.com", "bit.ly", false},
+		{"127.0.0.1:8080", "127.0.0.1:8080", true},
+		{"127.0.0.1:9090", "127.0.0.1:8080", false},
+		{"bit.ly", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host+" "+tt.domain, func(t *testing.T) {
+			if got := matchesDomain(tt.host, tt.domain); got != tt.want {
+				t.Errorf("matchesDomain(%q, %q) = %v, want %v", tt.host, tt.domain, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package shorturl
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// maxUnwrapDepth limits how many nested wrappers are unwrapped
+const maxUnwrapDepth = 5
+
+// unwrapper extracts the destination of a redirect wrapper from its query, so
+// wrapped links can be matched on their real domain without any network
+type unwrapper struct {
+	name string
+	// host matches the hosts the wrapper is served from
+	host *regexp.Regexp
+	// paths the wrapper is served at, empty for any path
+	paths []string
+	// params are the query parameters that may hold the destination, in order
+	params []string
+}
+
+var unwrappers = []unwrapper{
+	{
+		name:   "Outlook Safe Links",
+		host:   regexp.MustCompile(`(^|\.)safelinks\.protection\.outlook\.com$`),
+		params: []string{"url"},
+	},
+	{
+		name:   "Google",
+		host:   regexp.MustCompile(`(^|\.)google\.(com|[a-z]{2}|com?\.[a-z]{2})$`),
+		paths:  []string{"/url"},
+		params: []string{"q", "url"},
+	},
+	{
+		name:   "Facebook",
+		host:   regexp.MustCompile(`^(l|lm)\.(facebook|messenger)\.com$`),
+		paths:  []string{"/l.php", "/"},
+		params: []string{"u"},
+	},
+	{
+		name:   "Instagram",
+		host:   regexp.MustCompile(`^l\.instagram\.com$`),
+		params: []string{"u"},
+	},
+	{
+		name:   "LinkedIn",
+		host:   regexp.MustCompile(`(^|\.)linkedin\.com$`),
+		paths:  []string{"/redir/redirect", "/safety/go"},
+		params: []string{"url"},
+	},
+	{
+		name:   "Slack",
+		host:   regexp.MustCompile(`^slack-redir\.net$`),
+		paths:  []string{"/link"},
+		params: []string{"url"},
+	},
+	{
+		name:   "YouTube",
+		host:   regexp.MustCompile(`(^|\.)youtube\.com$`),
+		paths:  []string{"/redirect"},
+		params: []string{"q"},
+	},
+}
+
+// unwrapOnce returns the destination of a url if it's a known redirect wrapper
+func unwrapOnce(rawURL string) (string, string, bool) {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", false
+	}
+
+	host := strings.ToLower(parsedURL.Hostname())
+	for _, wrapper := range unwrappers {
+		if !wrapper.host.MatchString(host) {
+			continue
+		}
+		if len(wrapper.paths) > 0 && !containsPath(wrapper.paths, parsedURL.Path) {
+			continue
+		}
+
+		query := parsedURL.Query()
+		for _, param := range wrapper.params {
+			if destination, ok := decodeDestination(query.Get(param)); ok {
+				return destination, wrapper.name, true
+			}
+		}
+	}
+
+	return "", "", false
+}
+
+func containsPath(paths []string, path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	for _, candidate := range paths {
+		if strings.EqualFold(candidate, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeDestination returns a query value as an absolute http or https url,
+// decoding it again when the wrapper encoded it twice
+func decodeDestination(value string) (string, bool) {
+	for i := 0; i < 2 && value != ""; i++ {
+		if parsed, err := url.Parse(value); err == nil && parsed.Host != "" &&
+			(parsed.Scheme == "http" || parsed.Scheme == "https") {
+			return value, true
+		}
+
+		decoded, err := url.QueryUnescape(value)
+		if err != nil || decoded == value {
+			break
+		}
+		value = decoded
+	}
+	return "", false
+}
+
+// Unwrap returns the real destination of a url wrapped by known redirect
+// services like Outlook Safe Links, unwrapping nested wrappers, or the url
+// itself when it isn't wrapped
+func Unwrap(rawURL string) (string, []string) {
+	var wrappers []string
+	for len(wrappers) < maxUnwrapDepth {
+		destination, name, ok := unwrapOnce(rawURL)
+		if !ok {
+			break
+		}
+		wrappers = append(wrappers, name)
+		rawURL = destination
+	}
+	return rawURL, wrappers
+}
+
+// UnwrapChain unwraps a url like Unwrap, and returns the hops from the url to its destination
+func UnwrapChain(rawURL string) (string, []Hop) {
+	return unwrapAndLog(rawURL)
+}
@@ -0,0 +1,89 @@
+package shorturl
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestUnwrap(t *testing.T) {
+	destination := "https://example.com/path?a=1&b=2"
+	encoded := url.QueryEscape(destination)
+
+	tests := []struct {
+		name         string
+		url          string
+		want         string
+		wantWrappers []string
+	}{
+		{
+			name:         "Outlook Safe Links",
+			url:          "https://eur01.safelinks.protection.outlook.com/?url=" + encoded + "&data=05%7C02&reserved=0",
+			want:         destination,
+			wantWrappers: []string{"Outlook Safe Links"},
+		},
+		{
+			name:         "Google",
+			url:          "https://www.google.co.uk/url?sa=t&q=" + encoded,
+			want:         destination,
+			wantWrappers: []string{"Google"},
+		},
+		{
+			name:         "Facebook",
+			url:          "https://l.facebook.com/l.php?u=" + encoded + "&h=AT0",
+			want:         destination,
+			wantWrappers: []string{"Facebook"},
+		},
+		{
+			name:         "LinkedIn",
+			url:          "https://www.linkedin.com/redir/redirect?url=" + encoded + "&urlhash=abc",
+			want:         destination,
+			wantWrappers: []string{"LinkedIn"},
+		},
+		{
+			name:         "Slack",
+			url:          "https://slack-redir.net/link?url=" + encoded,
+			want:         destination,
+			wantWrappers: []string{"Slack"},
+		},
+		{
+			name:         "encoded twice",
+			url:          "https://slack-redir.net/link?url=" + url.QueryEscape(encoded),
+			want:         destination,
+			wantWrappers: []string{"Slack"},
+		},
+		{
+			name:         "nested wrappers",
+			url:          "https://nam12.safelinks.protection.outlook.com/?url=" + url.QueryEscape("https://www.google.com/url?q="+encoded),
+			want:         destination,
+			wantWrappers: []string{"Outlook Safe Links", "Google"},
+		},
+		{
+			name: "other paths on a wrapper host",
+			url:  "https://www.google.com/search?q=" + encoded,
+			want: "https://www.google.com/search?q=" + encoded,
+		},
+		{
+			name: "lookalike hosts",
+			url:  "https://notgoogle.com/url?q=" + encoded,
+			want: "https://notgoogle.com/url?q=" + encoded,
+		},
+		{
+			name: "destinations that aren't web urls",
+			url:  "https://slack-redir.net/link?url=" + url.QueryEscape("javascript:alert(1)"),
+			want: "https://slack-redir.net/link?url=" + url.QueryEscape("javascript:alert(1)"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, wrappers := Unwrap(tt.url)
+			if got != tt.want {
+				t.Errorf("Unwrap() = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(wrappers, tt.wantWrappers) {
+				t.Errorf("Unwrap() wrappers = %v, want %v", wrappers, tt.wantWrappers)
+			}
+		})
+	}
+}
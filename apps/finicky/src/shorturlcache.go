@@ -0,0 +1,40 @@
+package main
+
+import (
+	"finicky/config"
+	"finicky/shorturl"
+	"fmt"
+	"math"
+	"os"
+)
+
+// shortURLCacheFile is the name of the short url cache in the Finicky cache directory
+const shortURLCacheFile = "short_urls.json"
+
+// clearShortURLCache removes every cached short url and returns how many there were
+func clearShortURLCache() (int, error) {
+	cache := shortURLCache
+	if cache == nil {
+		// The cache may be disabled in the running config but still left on disk
+		cache = shorturl.NewCache(config.CachePath(shortURLCacheFile), math.MaxInt)
+	}
+	return cache.Clear()
+}
+
+// runCache implements `finicky cache clear`, which removes cached short urls,
+// and returns the process exit code
+func runCache(args []string) int {
+	if len(args) != 1 || args[0] != "clear" {
+		fmt.Fprintln(os.Stderr, "Usage: finicky cache clear")
+		return 2
+	}
+
+	count, err := clearShortURLCache()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to clear short URL cache: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("Removed %d cached short URL(s)\n", count)
+	return 0
+}
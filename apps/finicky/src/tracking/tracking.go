@@ -0,0 +1,127 @@
+package tracking
+
+import (
+	"embed"
+	"encoding/json"
+	"log/slog"
+	"net/url"
+	"strings"
+)
+
+//go:embed tracking_params.json
+var trackingParamsFS embed.FS
+
+// trackingParams is the built-in list of tracking parameters and the domains that need some of them
+type trackingParams struct {
+	Params     []string            `json:"params"`
+	Exceptions map[string][]string `json:"exceptions"`
+}
+
+var builtIn trackingParams
+
+func init() {
+	data, err := trackingParamsFS.ReadFile("tracking_params.json")
+	if err != nil {
+		slog.Error("Failed to read tracking parameters file", "error", err)
+		return
+	}
+
+	if err := json.Unmarshal(data, &builtIn); err != nil {
+		slog.Error("Failed to parse tracking parameters JSON", "error", err)
+		builtIn = trackingParams{}
+	}
+}
+
+// Stripper removes tracking parameters from urls. Parameters are matched by
+// name, or by prefix when the pattern ends with "*".
+type Stripper struct {
+	params     []string
+	exceptions map[string][]string
+}
+
+// NewStripper creates a stripper for the built-in tracking parameters, extended
+// with extra parameters and domains that need to keep some of them
+func NewStripper(extraParams []string, extraExceptions map[string][]string) *Stripper {
+	stripper := &Stripper{
+		params:     append(append([]string{}, builtIn.Params...), extraParams...),
+		exceptions: make(map[string][]string, len(builtIn.Exceptions)+len(extraExceptions)),
+	}
+	for _, exceptions := range []map[string][]string{builtIn.Exceptions, extraExceptions} {
+		for domain, params := range exceptions {
+			stripper.exceptions[domain] = append(stripper.exceptions[domain], params...)
+		}
+	}
+	return stripper
+}
+
+// matchesParam reports whether a parameter name matches any of the patterns
+func matchesParam(patterns []string, name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// keptParams returns the patterns of tracking parameters a host needs to keep
+func (s *Stripper) keptParams(host string) []string {
+	var kept []string
+	for domain, params := range s.exceptions {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			kept = append(kept, params...)
+		}
+	}
+	return kept
+}
+
+// Strip removes tracking parameters from a url and returns it with the names of
+// the parameters it removed. The rest of the url is kept exactly as it was.
+func (s *Stripper) Strip(rawURL string) (string, []string) {
+	base, fragment, hasFragment := strings.Cut(rawURL, "#")
+	base, query, hasQuery := strings.Cut(base, "?")
+	if !hasQuery || query == "" {
+		return rawURL, nil
+	}
+
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL, nil
+	}
+	kept := s.keptParams(strings.ToLower(parsedURL.Hostname()))
+
+	var stripped []string
+	var remaining []string
+	for _, pair := range strings.Split(query, "&") {
+		rawName, _, _ := strings.Cut(pair, "=")
+		name, err := url.QueryUnescape(rawName)
+		if err != nil {
+			name = rawName
+		}
+
+		if name != "" && matchesParam(s.params, name) && !matchesParam(kept, name) {
+			stripped = append(stripped, name)
+			continue
+		}
+		remaining = append(remaining, pair)
+	}
+
+	if len(stripped) == 0 {
+		return rawURL, nil
+	}
+
+	result := base
+	if len(remaining) > 0 {
+		result += "?" + strings.Join(remaining, "&")
+	}
+	if hasFragment {
+		result += "#" + fragment
+	}
+	return result, stripped
+}
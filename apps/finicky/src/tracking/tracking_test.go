@@ -0,0 +1,76 @@
+package tracking
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStrip(t *testing.T) {
+	stripper := NewStripper([]string{"ref_*"}, map[string][]string{"shop.example.com": {"gclid"}})
+
+	tests := []struct {
+		name         string
+		url          string
+		want         string
+		wantStripped []string
+	}{
+		{
+			name:         "utm parameters",
+			url:          "https://example.com/page?utm_source=news&id=5&utm_medium=email",
+			want:         "https://example.com/page?id=5",
+			wantStripped: []string{"utm_source", "utm_medium"},
+		},
+		{
+			name:         "keeps the fragment and other parameters as written",
+			url:          "https://example.com/?q=a%20b&fbclid=abc#section?x=1",
+			want:         "https://example.com/?q=a%20b#section?x=1",
+			wantStripped: []string{"fbclid"},
+		},
+		{
+			name:         "removes the question mark when nothing is left",
+			url:          "https://open.spotify.com/track/123?si=abc",
+			want:         "https://open.spotify.com/track/123",
+			wantStripped: []string{"si"},
+		},
+		{
+			name:         "extra parameters from options",
+			url:          "https://example.com/?ref_campaign=x&ref=y",
+			want:         "https://example.com/?ref=y",
+			wantStripped: []string{"ref_campaign"},
+		},
+		{
+			name:         "built-in exceptions",
+			url:          "https://analytics.google.com/?utm_source=x&gclid=y",
+			want:         "https://analytics.google.com/?utm_source=x",
+			wantStripped: []string{"gclid"},
+		},
+		{
+			name:         "exceptions from options",
+			url:          "https://shop.example.com/?gclid=y&fbclid=z",
+			want:         "https://shop.example.com/?gclid=y",
+			wantStripped: []string{"fbclid"},
+		},
+		{
+			name: "urls without tracking parameters",
+			url:  "https://example.com/?id=5",
+			want: "https://example.com/?id=5",
+		},
+		{
+			name: "urls without a query",
+			url:  "mailto:someone@example.com",
+			want: "mailto:someone@example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, stripped := stripper.Strip(tt.url)
+			if got != tt.want {
+				t.Errorf("Strip() = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(stripped, tt.wantStripped) {
+				t.Errorf("Strip() stripped = %v, want %v", stripped, tt.wantStripped)
+			}
+		})
+	}
+}
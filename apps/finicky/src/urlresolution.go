@@ -0,0 +1,89 @@
+package main
+
+import (
+	"finicky/shorturl"
+	"log/slog"
+	"strings"
+)
+
+// urlResolution resolves short urls for a single evaluation, so a config calling
+// finicky.resolveUrl several times with the same url only waits on the network once
+type urlResolution struct {
+	resolver *shorturl.Resolver
+	// offline only unwraps known redirect wrappers, for openers whose urls must never cause a request
+	offline  bool
+	resolved map[string]resolvedURL
+	// order are the resolved urls in the order they were first resolved
+	order []string
+}
+
+// resolvedURL is where a url led and the hops it took to get there
+type resolvedURL struct {
+	url   string
+	chain []shorturl.Hop
+}
+
+// newURLResolution starts resolving urls for an evaluation of a url from opener
+func newURLResolution(resolver *shorturl.Resolver, skipOpeners []string, opener *ProcessInfo) *urlResolution {
+	offline := opener != nil && matchesOpener(skipOpeners, opener)
+	if offline {
+		slog.Debug("Skipping short URL resolution for opener", "name", opener.Name, "bundleId", opener.BundleID)
+	}
+
+	return &urlResolution{
+		resolver: resolver,
+		offline:  offline,
+		resolved: make(map[string]resolvedURL),
+	}
+}
+
+// resolve returns where a url leads, or the best url known when resolving it fails
+func (r *urlResolution) resolve(url string) string {
+	resolved, _ := r.resolveChain(url)
+	return resolved
+}
+
+// resolveChain returns where a url leads and the hops it took to get there
+func (r *urlResolution) resolveChain(url string) (string, []shorturl.Hop) {
+	if resolved, ok := r.resolved[url]; ok {
+		return resolved.url, resolved.chain
+	}
+
+	var resolved resolvedURL
+	if r.offline {
+		resolved.url, resolved.chain = shorturl.UnwrapChain(url)
+	} else {
+		var err error
+		resolved.url, resolved.chain, err = r.resolver.Resolve(url)
+		if err != nil {
+			// Continue with the best known URL if resolution fails
+			slog.Info("Failed to resolve short URL", "error", err, "url", url, "using", resolved.url)
+		}
+	}
+
+	r.resolved[url] = resolved
+	r.order = append(r.order, url)
+	return resolved.url, resolved.chain
+}
+
+// redirectChain adds the hops of the urls resolved so far to chain, in the order
+// they were resolved
+func (r *urlResolution) redirectChain(chain []shorturl.Hop) []shorturl.Hop {
+	for _, url := range r.order {
+		chain = shorturl.AppendChain(chain, r.resolved[url].chain)
+	}
+	return chain
+}
+
+// matchesOpener reports whether an opener is in a list of app names and bundle ids
+func matchesOpener(openers []string, opener *ProcessInfo) bool {
+	for _, candidate := range openers {
+		if candidate == "" {
+			continue
+		}
+		if strings.EqualFold(candidate, opener.Name) || strings.EqualFold(candidate, opener.BundleID) {
+			return true
+		}
+	}
+	return false
+}
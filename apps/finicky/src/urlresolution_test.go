@@ -0,0 +1,130 @@
+package main
+
+import (
+	"finicky/shorturl"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestURLResolution(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	landingURL := target.URL + "/landing"
+
+	requests := 0
+	shortener := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Redirect(w, r, landingURL, http.StatusMovedPermanently)
+	}))
+	defer shortener.Close()
+
+	shortenerHost, _ := url.Parse(shortener.URL)
+	resolver := shorturl.NewResolver()
+	resolver.Timeout = 100 * time.Millisecond
+	resolver.Methods = []string{http.MethodGet}
+	resolver.ExtraDomains = []string{shortenerHost.Host}
+
+	shortURL := shortener.URL + "/abc"
+	wrappedURL := "https://www.google.com/url?q=" + url.QueryEscape(shortURL)
+	opener := &ProcessInfo{Name: "Slack", BundleID: "com.tinyspeck.slackmacgap"}
+
+	tests := []struct {
+		name        string
+		skipOpeners []string
+		// unwrapped is the url unwrapped before the config runs, as in lazy mode
+		unwrapped string
+		urls      []string
+		expected  string
+		requests  int
+		chain     []shorturl.Hop
+	}{
+		{
+			name:     "resolves a url once",
+			urls:     []string{shortURL, shortURL},
+			expected: landingURL,
+			requests: 1,
+			chain: []shorturl.Hop{
+				{URL: shortURL, Status: http.StatusMovedPermanently, Host: "127.0.0.1"},
+				{URL: landingURL, Status: http.StatusOK, Host: "127.0.0.1"},
+			},
+		},
+		{
+			name:      "adds the urls resolved by the config to the unwrapped ones",
+			unwrapped: wrappedURL,
+			urls:      []string{shortURL},
+			expected:  landingURL,
+			requests:  1,
+			chain: []shorturl.Hop{
+				{URL: wrappedURL, Host: "www.google.com"},
+				{URL: shortURL, Status: http.StatusMovedPermanently, Host: "127.0.0.1"},
+				{URL: landingURL, Status: http.StatusOK, Host: "127.0.0.1"},
+			},
+		},
+		{
+			name:        "only unwraps for skipped openers",
+			skipOpeners: []string{"", "slack"},
+			urls:        []string{wrappedURL},
+			expected:    shortURL,
+			requests:    0,
+			chain: []shorturl.Hop{
+				{URL: wrappedURL, Host: "www.google.com"},
+				{URL: shortURL, Host: "127.0.0.1"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = 0
+			resolution := newURLResolution(resolver, tt.skipOpeners, opener)
+
+			var got string
+			for _, rawURL := range tt.urls {
+				got = resolution.resolve(rawURL)
+			}
+			if got != tt.expected {
+				t.Errorf("resolve() = %q, want %q", got, tt.expected)
+			}
+			if requests != tt.requests {
+				t.Errorf("requests = %d, want %d", requests, tt.requests)
+			}
+
+			var chain []shorturl.Hop
+			if tt.unwrapped != "" {
+				_, chain = shorturl.UnwrapChain(tt.unwrapped)
+			}
+			if chain = resolution.redirectChain(chain); !slices.Equal(chain, tt.chain) {
+				t.Errorf("redirectChain() = %+v, want %+v", chain, tt.chain)
+			}
+		})
+	}
+}
+
+func TestMatchesOpener(t *testing.T) {
+	opener := &ProcessInfo{Name: "Slack", BundleID: "com.tinyspeck.slackmacgap"}
+
+	tests := []struct {
+		name    string
+		openers []string
+		want    bool
+	}{
+		{"name", []string{"Slack"}, true},
+		{"bundle id ignoring case", []string{"COM.TINYSPECK.SLACKMACGAP"}, true},
+		{"empty entries", []string{""}, false},
+		{"other apps", []string{"Mail", "com.apple.mail"}, false},
+		{"part of the name", []string{"slac"}, false},
+		{"no openers", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchesOpener(tt.openers, opener); got != tt.want {
+				t.Errorf("matchesOpener(%q) = %v, want %v", tt.openers, got, tt.want)
+			}
+		})
+	}
+}
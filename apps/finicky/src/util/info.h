@@ -23,11 +23,19 @@ typedef struct {
     int percentage;
 } PowerInfo;
 
+typedef struct {
+    char* httpProxy;
+    char* httpsProxy;
+    char* exceptions;
+} ProxySettings;
+
 ModifierKeys getModifierKeys(void);
 SystemInfo getSystemInfo(void);
 PowerInfo getPowerInfo(void);
 _Bool isAppRunning(const char* identifier);
 const char* getNSHomeDirectory(void);
 const char* getNSCacheDirectory(void);
+ProxySettings getSystemProxySettings(void);
+_Bool isHostReachable(const char* host);
 
 #endif /* INFO_H */
\ No newline at end of file
@@ -2,6 +2,8 @@
 #import <Cocoa/Cocoa.h>
 #import <IOKit/ps/IOPSKeys.h>
 #import <IOKit/ps/IOPowerSources.h>
+#import <CFNetwork/CFNetwork.h>
+#import <SystemConfiguration/SystemConfiguration.h>
 #import <stdlib.h>
 #import <string.h>
 
@@ -130,3 +132,68 @@ const char* getNSCacheDirectory(void) {
     }
     return NULL;
 }
+
+// copyProxy returns the host:port of an enabled proxy, which the caller frees, or NULL
+static char* copyProxy(NSDictionary *settings, CFStringRef enableKey, CFStringRef hostKey, CFStringRef portKey) {
+    if (![settings[(NSString *)enableKey] boolValue]) {
+        return NULL;
+    }
+
+    NSString *host = settings[(NSString *)hostKey];
+    if (host.length == 0) {
+        return NULL;
+    }
+
+    NSNumber *port = settings[(NSString *)portKey];
+    NSString *proxy = port ? [NSString stringWithFormat:@"%@:%@", host, port] : host;
+    return strdup([proxy UTF8String]);
+}
+
+ProxySettings getSystemProxySettings(void) {
+    ProxySettings proxies = {
+        .httpProxy = NULL,
+        .httpsProxy = NULL,
+        .exceptions = NULL
+    };
+
+    CFDictionaryRef settingsRef = CFNetworkCopySystemProxySettings();
+    if (!settingsRef) {
+        return proxies;
+    }
+
+    @autoreleasepool {
+        NSDictionary *settings = (NSDictionary *)settingsRef;
+        proxies.httpProxy = copyProxy(settings, kCFNetworkProxiesHTTPEnable, kCFNetworkProxiesHTTPProxy, kCFNetworkProxiesHTTPPort);
+        proxies.httpsProxy = copyProxy(settings, kCFNetworkProxiesHTTPSEnable, kCFNetworkProxiesHTTPSProxy, kCFNetworkProxiesHTTPSPort);
+
+        NSArray *exceptions = settings[(NSString *)kCFNetworkProxiesExceptionsList];
+        if (exceptions.count > 0) {
+            proxies.exceptions = strdup([[exceptions componentsJoinedByString:@","] UTF8String]);
+        }
+    }
+
+    CFRelease(settingsRef);
+    return proxies;
+}
+
+_Bool isHostReachable(const char* host) {
+    if (!host) {
+        return 0;
+    }
+
+    SCNetworkReachabilityRef reachability = SCNetworkReachabilityCreateWithName(NULL, host);
+    if (!reachability) {
+        // Without an answer the request itself finds out
+        return 1;
+    }
+
+    _Bool reachable = 1;
+    SCNetworkReachabilityFlags flags;
+    if (SCNetworkReachabilityGetFlags(reachability, &flags)) {
+        reachable = (flags & kSCNetworkReachabilityFlagsReachable) != 0 &&
+            (flags & kSCNetworkReachabilityFlagsConnectionRequired) == 0;
+    }
+
+    CFRelease(reachability);
+    return reachable;
+}
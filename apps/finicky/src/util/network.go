@@ -0,0 +1,47 @@
+package util
+
+/*
+#cgo LDFLAGS: -framework CFNetwork -framework SystemConfiguration
+#include <stdlib.h>
+#include "info.h"
+*/
+import "C"
+import (
+	"strings"
+	"unsafe"
+)
+
+// ProxySettings are the proxies set in the macOS network settings
+type ProxySettings struct {
+	// HTTP and HTTPS are the host:port of the proxies, empty when they're off
+	HTTP  string
+	HTTPS string
+	// Exceptions are the hosts and domains that bypass the proxies
+	Exceptions []string
+}
+
+// SystemProxySettings returns the proxies set in the macOS network settings
+func SystemProxySettings() ProxySettings {
+	proxies := C.getSystemProxySettings()
+	defer C.free(unsafe.Pointer(proxies.httpProxy))
+	defer C.free(unsafe.Pointer(proxies.httpsProxy))
+	defer C.free(unsafe.Pointer(proxies.exceptions))
+
+	settings := ProxySettings{
+		HTTP:  C.GoString(proxies.httpProxy),
+		HTTPS: C.GoString(proxies.httpsProxy),
+	}
+	if exceptions := C.GoString(proxies.exceptions); exceptions != "" {
+		settings.Exceptions = strings.Split(exceptions, ",")
+	}
+	return settings
+}
+
+// IsHostReachable reports whether the network can reach a host, without connecting to it.
+// It's false when the Mac is offline.
+func IsHostReachable(host string) bool {
+	cHost := C.CString(host)
+	defer C.free(unsafe.Pointer(cHost))
+
+	return bool(C.isHostReachable(cHost))
+}
@@ -0,0 +1,85 @@
+package version
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// proxySettings are the proxies set in the network settings
+type proxySettings struct {
+	// HTTP and HTTPS are the host:port of the proxies, empty when they're off
+	HTTP  string
+	HTTPS string
+	// Exceptions are the hosts and domains that bypass the proxies
+	Exceptions []string
+}
+
+// newHTTPClient returns a client that goes through the proxy set in HTTPS_PROXY or
+// HTTP_PROXY, or else through the proxies in the macOS network settings
+func newHTTPClient(timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyForRequest
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+func proxyForRequest(req *http.Request) (*url.URL, error) {
+	if hasProxyEnvironment() {
+		return http.ProxyFromEnvironment(req)
+	}
+	return systemProxy(systemProxySettings(), req.URL)
+}
+
+// hasProxyEnvironment reports whether a proxy is set in the environment, which then
+// takes precedence over the system settings
+func hasProxyEnvironment() bool {
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy"} {
+		if os.Getenv(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// systemProxy returns the proxy from the network settings for a url, or nil when
+// there's none or the host is one of the exceptions
+func systemProxy(settings proxySettings, target *url.URL) (*url.URL, error) {
+	proxy := settings.HTTP
+	if target.Scheme == "https" {
+		proxy = settings.HTTPS
+	}
+	if proxy == "" || bypassesProxy(settings.Exceptions, target.Hostname()) {
+		return nil, nil
+	}
+	return url.Parse("http://" + proxy)
+}
+
+// bypassesProxy reports whether a host matches one of the proxy exceptions, which
+// are host names or domains written as *.example.com or .example.com
+func bypassesProxy(exceptions []string, host string) bool {
+	host = strings.ToLower(host)
+	for _, exception := range exceptions {
+		exception = strings.ToLower(strings.TrimSpace(exception))
+		domain := strings.TrimPrefix(strings.TrimPrefix(exception, "*"), ".")
+		switch {
+		case domain == "":
+			continue
+		case host == domain:
+			return true
+		case domain != exception && strings.HasSuffix(host, "."+domain):
+			return true
+		}
+	}
+	return false
+}
+
+// isOffline reports whether the network can't reach the host of a url
+func isOffline(rawURL string) bool {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil || parsedURL.Hostname() == "" {
+		return false
+	}
+	return !isHostReachable(parsedURL.Hostname())
+}
@@ -0,0 +1,50 @@
+package version
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestSystemProxy(t *testing.T) {
+	settings := proxySettings{
+		HTTP:       "proxy.example.com:3128",
+		HTTPS:      "secure-proxy.example.com:443",
+		Exceptions: []string{"localhost", "*.local", ".corp.example.com", " Intranet "},
+	}
+
+	tests := []struct {
+		name     string
+		settings proxySettings
+		url      string
+		expected string
+	}{
+		{"http url", settings, "http://api.finicky.app/update-check", "http://proxy.example.com:3128"},
+		{"https url", settings, "https://api.finicky.app/update-check", "http://secure-proxy.example.com:443"},
+		{"exception", settings, "http://localhost:8080", ""},
+		{"wildcard exception", settings, "https://printer.local", ""},
+		{"wildcard exception doesn't match other domains", settings, "https://notlocal", "http://secure-proxy.example.com:443"},
+		{"domain exception", settings, "https://updates.corp.example.com", ""},
+		{"domain exception matches the domain", settings, "https://corp.example.com", ""},
+		{"host exception doesn't match subdomains", settings, "https://www.intranet", "http://secure-proxy.example.com:443"},
+		{"host exception ignores case and spaces", settings, "https://INTRANET", ""},
+		{"no proxy for the scheme", proxySettings{HTTP: "proxy.example.com:3128"}, "https://api.finicky.app", ""},
+		{"no proxies", proxySettings{}, "https://api.finicky.app", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, _ := url.Parse(tt.url)
+			proxy, err := systemProxy(tt.settings, target)
+			if err != nil {
+				t.Fatalf("systemProxy() error = %v", err)
+			}
+			got := ""
+			if proxy != nil {
+				got = proxy.String()
+			}
+			if got != tt.expected {
+				t.Errorf("systemProxy() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package version
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf16"
+)
+
+// errKeyNotFound is returned when a plist has no value for a key
+var errKeyNotFound = errors.New("key not found")
+
+// readPlistString returns the string value of a key in the top level dictionary
+// of a property list, in either the XML or the binary format
+func readPlistString(data []byte, key string) (string, error) {
+	if bytes.HasPrefix(data, []byte("bplist00")) {
+		return readBinaryPlistString(data, key)
+	}
+	return readXMLPlistString(data, key)
+}
+
+// readXMLPlistString finds a key in the top level dictionary of an XML plist
+func readXMLPlistString(data []byte, key string) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	// Plists declare a DTD and are always UTF-8
+	decoder.Strict = false
+
+	// Finds the top level dictionary
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", fmt.Errorf("invalid plist: %v", err)
+		}
+		if start, ok := token.(xml.StartElement); ok && start.Name.Local == "dict" {
+			break
+		}
+	}
+
+	currentKey := ""
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return "", errKeyNotFound
+		}
+		if err != nil {
+			return "", fmt.Errorf("invalid plist: %v", err)
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			var text string
+			if element.Name.Local == "key" || element.Name.Local == "string" {
+				if err := decoder.DecodeElement(&text, &element); err != nil {
+					return "", fmt.Errorf("invalid plist: %v", err)
+				}
+			} else if err := decoder.Skip(); err != nil {
+				return "", fmt.Errorf("invalid plist: %v", err)
+			}
+
+			switch {
+			case element.Name.Local == "key":
+				currentKey = text
+				continue
+			case currentKey == key && element.Name.Local == "string":
+				return text, nil
+			case currentKey == key:
+				return "", fmt.Errorf("%s is a %s, not a string", key, element.Name.Local)
+			}
+			currentKey = ""
+		case xml.EndElement:
+			// The end of the top level dictionary
+			return "", errKeyNotFound
+		}
+	}
+}
+
+// binaryPlist reads objects from a binary plist, the format of compiled app bundles
+type binaryPlist struct {
+	data          []byte
+	offsets       []uint64
+	objectRefSize int
+}
+
+// readBinaryPlistString finds a key in the top level dictionary of a binary plist
+func readBinaryPlistString(data []byte, key string) (string, error) {
+	const headerSize, trailerSize = 8, 32
+	if len(data) < headerSize+trailerSize {
+		return "", errors.New("invalid binary plist: too short")
+	}
+
+	trailer := data[len(data)-trailerSize:]
+	offsetSize := int(trailer[6])
+	objectRefSize := int(trailer[7])
+	numObjects := binary.BigEndian.Uint64(trailer[8:16])
+	topObject := binary.BigEndian.Uint64(trailer[16:24])
+	offsetTableOffset := binary.BigEndian.Uint64(trailer[24:32])
+
+	if offsetSize < 1 || offsetSize > 8 || objectRefSize < 1 || objectRefSize > 8 ||
+		numObjects > uint64(len(data)) || offsetTableOffset > uint64(len(data)) ||
+		offsetTableOffset+numObjects*uint64(offsetSize) > uint64(len(data)) {
+		return "", errors.New("invalid binary plist: bad trailer")
+	}
+
+	plist := &binaryPlist{data: data, objectRefSize: objectRefSize, offsets: make([]uint64, numObjects)}
+	for i := range plist.offsets {
+		start := offsetTableOffset + uint64(i*offsetSize)
+		plist.offsets[i] = readUint(data[start : start+uint64(offsetSize)])
+	}
+
+	keys, values, err := plist.dict(topObject)
+	if err != nil {
+		return "", err
+	}
+	for i, keyRef := range keys {
+		if name, err := plist.string(keyRef); err == nil && name == key {
+			return plist.string(values[i])
+		}
+	}
+	return "", errKeyNotFound
+}
+
+// object returns the marker of an object and the bytes following it
+func (p *binaryPlist) object(ref uint64) (byte, []byte, error) {
+	if ref >= uint64(len(p.offsets)) || p.offsets[ref] >= uint64(len(p.data)) {
+		return 0, nil, fmt.Errorf("invalid binary plist: bad object reference %d", ref)
+	}
+	offset := p.offsets[ref]
+	return p.data[offset], p.data[offset+1:], nil
+}
+
+// length returns the element count of a string, array or dictionary object and the bytes following it
+func (p *binaryPlist) length(marker byte, rest []byte) (uint64, []byte, error) {
+	count := uint64(marker & 0x0f)
+	if count != 0x0f {
+		return count, rest, nil
+	}
+
+	// Longer counts follow as an int object
+	if len(rest) < 1 || rest[0]&0xf0 != 0x10 {
+		return 0, nil, errors.New("invalid binary plist: bad length")
+	}
+	size := 1 << (rest[0] & 0x0f)
+	if len(rest) < 1+size || size > 8 {
+		return 0, nil, errors.New("invalid binary plist: bad length")
+	}
+	return readUint(rest[1 : 1+size]), rest[1+size:], nil
+}
+
+// string decodes an ASCII or UTF-16 string object
+func (p *binaryPlist) string(ref uint64) (string, error) {
+	marker, rest, err := p.object(ref)
+	if err != nil {
+		return "", err
+	}
+
+	kind := marker & 0xf0
+	if kind != 0x50 && kind != 0x60 {
+		return "", fmt.Errorf("object %d is not a string", ref)
+	}
+
+	count, rest, err := p.length(marker, rest)
+	if err != nil {
+		return "", err
+	}
+
+	if kind == 0x50 {
+		if count > uint64(len(rest)) {
+			return "", errors.New("invalid binary plist: string out of bounds")
+		}
+		return string(rest[:count]), nil
+	}
+
+	if count*2 > uint64(len(rest)) {
+		return "", errors.New("invalid binary plist: string out of bounds")
+	}
+	units := make([]uint16, count)
+	for i := range units {
+		units[i] = binary.BigEndian.Uint16(rest[i*2:])
+	}
+	return string(utf16.Decode(units)), nil
+}
+
+// dict returns the key and value references of a dictionary object
+func (p *binaryPlist) dict(ref uint64) ([]uint64, []uint64, error) {
+	marker, rest, err := p.object(ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	if marker&0xf0 != 0xd0 {
+		return nil, nil, errors.New("invalid binary plist: top object is not a dictionary")
+	}
+
+	count, rest, err := p.length(marker, rest)
+	if err != nil {
+		return nil, nil, err
+	}
+	if count*2*uint64(p.objectRefSize) > uint64(len(rest)) {
+		return nil, nil, errors.New("invalid binary plist: dictionary out of bounds")
+	}
+
+	refs := make([]uint64, count*2)
+	for i := range refs {
+		refs[i] = readUint(rest[i*p.objectRefSize : (i+1)*p.objectRefSize])
+	}
+	return refs[:count], refs[count:], nil
+}
+
+// readUint reads a big endian unsigned integer of up to 8 bytes
+func readUint(data []byte) uint64 {
+	var value uint64
+	for _, b := range data {
+		value = value<<8 | uint64(b)
+	}
+	return value
+}
@@ -0,0 +1,81 @@
+package version
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const xmlPlist = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>CFBundleDocumentTypes</key>
+	<array>
+		<dict>
+			<key>CFBundleVersion</key>
+			<string>nested</string>
+		</dict>
+	</array>
+	<key>LSUIElement</key>
+	<true/>
+	<key>CFBundleVersion</key>
+	<string>4.2.0</string>
+</dict>
+</plist>`
+
+func TestReadPlistString(t *testing.T) {
+	// Written by Python's plistlib, with a UTF-16 string and a long string
+	binaryPlist, _ := base64.StdEncoding.DecodeString("YnBsaXN0MDDVAQIDBAUGBwgJCl8QFUNGQnVuZGxlR2V0SW5mb1N0cmluZ1xDRkJ1bmRsZU5hbWVfEA9DRkJ1bmRsZVZlcnNpb25fEBZMU01pbmltdW1TeXN0ZW1WZXJzaW9uXxAYTlNIdW1hblJlYWRhYmxlQ29weXJpZ2h0ZABDAGEAZgDpV0Zpbmlja3lVNC4yLjBUMTIuMF8QFHh4eHh4eHh4eHh4eHh4eHh4eHh4CBMrOEpjfoePlZoAAAAAAAABAQAAAAAAAAALAAAAAAAAAAAAAAAAAAAAsQ==")
+
+	tests := []struct {
+		name    string
+		data    []byte
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{"XML plist", []byte(xmlPlist), "CFBundleVersion", "4.2.0", false},
+		{"XML plist missing key", []byte(xmlPlist), "CFBundleName", "", true},
+		{"XML plist value that isn't a string", []byte(xmlPlist), "LSUIElement", "", true},
+		{"binary plist", binaryPlist, "CFBundleVersion", "4.2.0", false},
+		{"binary plist UTF-16 string", binaryPlist, "CFBundleGetInfoString", "Café", false},
+		{"binary plist long string", binaryPlist, "NSHumanReadableCopyright", "xxxxxxxxxxxxxxxxxxxx", false},
+		{"binary plist missing key", binaryPlist, "CFBundleExecutable", "", true},
+		{"truncated binary plist", binaryPlist[:40], "CFBundleVersion", "", true},
+		{"not a plist", []byte("4.2.0"), "CFBundleVersion", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readPlistString(tt.data, tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readPlistString() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("readPlistString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadBundleVersion(t *testing.T) {
+	bundle := filepath.Join(t.TempDir(), "Finicky.app")
+	plist := filepath.Join(bundle, "Contents", "Info.plist")
+	if err := os.MkdirAll(filepath.Dir(plist), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(plist, []byte(xmlPlist), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{plist, bundle, filepath.Dir(plist)} {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			got, err := readBundleVersion(path)
+			if err != nil || got != "4.2.0" {
+				t.Errorf("readBundleVersion() = %q, %v, want 4.2.0", got, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package version
+
+import "finicky/util"
+
+// The macOS lookups of the package are kept here so the rest of it builds and
+// tests on other platforms, see system_other.go
+
+func userCacheDir() (string, error) {
+	return util.UserCacheDir()
+}
+
+func systemProxySettings() proxySettings {
+	settings := util.SystemProxySettings()
+	return proxySettings{HTTP: settings.HTTP, HTTPS: settings.HTTPS, Exceptions: settings.Exceptions}
+}
+
+func isHostReachable(host string) bool {
+	return util.IsHostReachable(host)
+}
@@ -0,0 +1,19 @@
+//go:build !darwin
+
+package version
+
+import "os"
+
+func userCacheDir() (string, error) {
+	return os.UserCacheDir()
+}
+
+// systemProxySettings has no system proxies to read, only the environment ones apply
+func systemProxySettings() proxySettings {
+	return proxySettings{}
+}
+
+// isHostReachable can't tell, so it never reports being offline
+func isHostReachable(host string) bool {
+	return true
+}
@@ -0,0 +1,331 @@
+package version
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// States of a downloaded update
+const (
+	UpdateDownloading = "downloading"
+	UpdateReady       = "ready"
+	UpdateFailed      = "failed"
+)
+
+// progressInterval is how many bytes are downloaded between progress reports
+const progressInterval = 256 << 10
+
+// UpdateProgress is how far the download of an update has come
+type UpdateProgress struct {
+	Version    string `json:"version"`
+	State      string `json:"state"`
+	Downloaded int64  `json:"downloaded"`
+	// Total is the size of the archive, zero while it isn't known
+	Total int64 `json:"total"`
+	// Path is the verified archive once the update is ready to install
+	Path  string `json:"path,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Updater downloads release archives to a staging directory and only keeps
+// them once their checksum and signature are verified
+type Updater struct {
+	// StagingDir holds the downloaded archives, one directory per version
+	StagingDir string
+	// PublicKey verifies the signatures of releases
+	PublicKey ed25519.PublicKey
+	Client    *http.Client
+	// OnProgress is called as the download progresses and when it ends
+	OnProgress func(UpdateProgress)
+}
+
+// NewUpdater returns an updater staging archives in the cache directory and
+// verifying them with the key set at build time
+func NewUpdater(onProgress func(UpdateProgress)) (*Updater, error) {
+	if updatePublicKey == "" {
+		return nil, fmt.Errorf("updatePublicKey is not set")
+	}
+	key, err := base64.StdEncoding.DecodeString(updatePublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid updatePublicKey")
+	}
+
+	cacheDir := getCacheDir()
+	if cacheDir == "" {
+		return nil, fmt.Errorf("no cache directory")
+	}
+
+	return &Updater{
+		StagingDir: filepath.Join(cacheDir, "updates"),
+		PublicKey:  ed25519.PublicKey(key),
+		Client:     newHTTPClient(30 * time.Minute),
+		OnProgress: onProgress,
+	}, nil
+}
+
+// Download downloads the archive of a release, resuming a partial download left
+// by a previous run, and returns the path of the verified archive. The signature
+// is checked before anything is downloaded.
+func (u *Updater) Download(ctx context.Context, release ReleaseInfo) (string, error) {
+	progress := UpdateProgress{Version: release.LatestVersion, State: UpdateDownloading}
+
+	archivePath, err := u.download(ctx, release, &progress)
+	if err != nil {
+		progress.State = UpdateFailed
+		progress.Error = err.Error()
+		u.report(progress)
+		return "", err
+	}
+
+	progress.State = UpdateReady
+	progress.Path = archivePath
+	u.report(progress)
+	return archivePath, nil
+}
+
+func (u *Updater) download(ctx context.Context, release ReleaseInfo, progress *UpdateProgress) (string, error) {
+	digest, err := u.verifySignature(release)
+	if err != nil {
+		return "", err
+	}
+
+	name, err := archiveName(release.DownloadUrl)
+	if err != nil {
+		return "", err
+	}
+
+	versionDir := filepath.Join(u.StagingDir, sanitizeVersion(release.LatestVersion))
+	if err := os.MkdirAll(versionDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create staging directory: %v", err)
+	}
+	u.removeOtherVersions(versionDir)
+
+	archivePath := filepath.Join(versionDir, name)
+	partialPath := archivePath + ".part"
+
+	// An archive verified by a previous run is ready as is
+	if info, err := os.Stat(archivePath); err == nil {
+		if verifyChecksum(archivePath, digest) == nil {
+			progress.Downloaded, progress.Total = info.Size(), info.Size()
+			return archivePath, nil
+		}
+		os.Remove(archivePath)
+	}
+
+	if err := u.fetch(ctx, release.DownloadUrl, partialPath, progress); err != nil {
+		return "", err
+	}
+
+	if err := verifyChecksum(partialPath, digest); err != nil {
+		// Starting over is the only way out of a corrupted download
+		os.Remove(partialPath)
+		return "", err
+	}
+
+	if err := os.Rename(partialPath, archivePath); err != nil {
+		return "", fmt.Errorf("failed to stage update: %v", err)
+	}
+	slog.Info("Update downloaded and verified", "version", release.LatestVersion, "path", archivePath)
+	return archivePath, nil
+}
+
+// verifySignature checks the signature of a release's SHA-256 checksum and
+// returns the checksum
+func (u *Updater) verifySignature(release ReleaseInfo) ([]byte, error) {
+	digest, err := hex.DecodeString(release.SHA256)
+	if err != nil || len(digest) != sha256.Size {
+		return nil, fmt.Errorf("release has no valid SHA-256 checksum")
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(release.Signature)
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("release has no valid signature")
+	}
+
+	if !ed25519.Verify(u.PublicKey, digest, signature) {
+		return nil, fmt.Errorf("release signature doesn't match")
+	}
+	return digest, nil
+}
+
+// fetch downloads a url to a file, continuing from where a previous download of
+// the file stopped when the server supports it
+func (u *Updater) fetch(ctx context.Context, downloadURL string, partialPath string, progress *UpdateProgress) error {
+	var offset int64
+	if info, err := os.Stat(partialPath); err == nil {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, downloadURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("User-Agent", fmt.Sprintf("finicky/%s", GetCurrentVersion()))
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := u.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download update: %v", err)
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		slog.Debug("Resuming update download", "offset", offset)
+		flags |= os.O_APPEND
+		progress.Total = contentRangeTotal(resp.Header.Get("Content-Range"))
+	case http.StatusOK:
+		// The server ignored the range, so the download starts over
+		offset = 0
+		flags |= os.O_TRUNC
+		progress.Total = max(resp.ContentLength, 0)
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The previous run already downloaded everything
+		progress.Downloaded, progress.Total = offset, offset
+		return nil
+	default:
+		return fmt.Errorf("failed to download update: %s", resp.Status)
+	}
+
+	file, err := os.OpenFile(partialPath, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write update: %v", err)
+	}
+	defer file.Close()
+
+	progress.Downloaded = offset
+	u.report(*progress)
+
+	writer := &progressWriter{
+		writer: file,
+		onWrite: func(written int64) {
+			progress.Downloaded = offset + written
+			u.report(*progress)
+		},
+	}
+	if _, err := io.Copy(writer, resp.Body); err != nil {
+		return fmt.Errorf("failed to download update: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write update: %v", err)
+	}
+
+	progress.Downloaded = offset + writer.written
+	return nil
+}
+
+// removeOtherVersions deletes archives staged for other versions
+func (u *Updater) removeOtherVersions(keep string) {
+	entries, err := os.ReadDir(u.StagingDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entryPath := filepath.Join(u.StagingDir, entry.Name()); entryPath != keep {
+			slog.Debug("Removing staged update", "path", entryPath)
+			os.RemoveAll(entryPath)
+		}
+	}
+}
+
+func (u *Updater) report(progress UpdateProgress) {
+	if u.OnProgress != nil {
+		u.OnProgress(progress)
+	}
+}
+
+// progressWriter counts the bytes written through it, reporting them every progressInterval bytes
+type progressWriter struct {
+	writer   io.Writer
+	written  int64
+	reported int64
+	onWrite  func(written int64)
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.written += int64(n)
+	if w.written-w.reported >= progressInterval {
+		w.reported = w.written
+		w.onWrite(w.written)
+	}
+	return n, err
+}
+
+// verifyChecksum checks that the SHA-256 checksum of a file is digest
+func verifyChecksum(filePath string, digest []byte) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read update: %v", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return fmt.Errorf("failed to read update: %v", err)
+	}
+	if !bytes.Equal(hash.Sum(nil), digest) {
+		return errors.New("update checksum doesn't match")
+	}
+	return nil
+}
+
+// archiveName returns the file name of a download url
+func archiveName(downloadURL string) (string, error) {
+	parsedURL, err := url.Parse(downloadURL)
+	if err != nil || (parsedURL.Scheme != "https" && parsedURL.Scheme != "http") {
+		return "", fmt.Errorf("invalid download url: %q", downloadURL)
+	}
+	name := path.Base(parsedURL.Path)
+	if name == "/" || name == "." || name == ".." || name == "" {
+		return "", fmt.Errorf("download url has no file name: %q", downloadURL)
+	}
+	return name, nil
+}
+
+// sanitizeVersion makes a version safe to use as a directory name in the staging directory
+func sanitizeVersion(version string) string {
+	version = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' {
+			return '_'
+		}
+		return r
+	}, strings.TrimPrefix(version, "v"))
+
+	if strings.Trim(version, ".") == "" {
+		return "_" + version
+	}
+	return version
+}
+
+// contentRangeTotal returns the total size from a Content-Range header, or zero when it's unknown
+func contentRangeTotal(contentRange string) int64 {
+	_, total, ok := strings.Cut(contentRange, "/")
+	if !ok {
+		return 0
+	}
+	size, err := strconv.ParseInt(total, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return size
+}
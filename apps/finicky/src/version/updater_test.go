@@ -0,0 +1,146 @@
+package version
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpdaterDownload(t *testing.T) {
+	archive := bytes.Repeat([]byte("finicky"), 100000)
+	digest := sha256.Sum256(archive)
+
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherKey, _ := ed25519.GenerateKey(nil)
+
+	var ranges []string
+	ignoreRange := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if ignoreRange {
+			r.Header.Del("Range")
+		}
+		http.ServeContent(w, r, "Finicky.zip", time.Time{}, bytes.NewReader(archive))
+	}))
+	defer server.Close()
+
+	release := func(key ed25519.PrivateKey, checksum []byte) ReleaseInfo {
+		return ReleaseInfo{
+			LatestVersion: "v4.2.0",
+			DownloadUrl:   server.URL + "/releases/Finicky.zip",
+			SHA256:        hex.EncodeToString(checksum),
+			Signature:     base64.StdEncoding.EncodeToString(ed25519.Sign(key, checksum)),
+		}
+	}
+	wrongDigest := sha256.Sum256([]byte("something else"))
+
+	tests := []struct {
+		name        string
+		release     ReleaseInfo
+		partial     []byte
+		ignoreRange bool
+		wantRanges  []string
+		wantErr     string
+	}{
+		{
+			name:       "downloads and verifies the archive",
+			release:    release(privateKey, digest[:]),
+			wantRanges: []string{""},
+		},
+		{
+			name:       "resumes a partial download",
+			release:    release(privateKey, digest[:]),
+			partial:    archive[:1000],
+			wantRanges: []string{"bytes=1000-"},
+		},
+		{
+			name:        "starts over when the server ignores the range",
+			release:     release(privateKey, digest[:]),
+			partial:     archive[:1000],
+			ignoreRange: true,
+			wantRanges:  []string{"bytes=1000-"},
+		},
+		{
+			name:       "starts over after a corrupted partial download",
+			release:    release(privateKey, digest[:]),
+			partial:    bytes.Repeat([]byte("x"), 1000),
+			wantRanges: []string{"bytes=1000-"},
+			wantErr:    "checksum doesn't match",
+		},
+		{
+			name:       "rejects an archive with the wrong checksum",
+			release:    release(privateKey, wrongDigest[:]),
+			wantRanges: []string{""},
+			wantErr:    "checksum doesn't match",
+		},
+		{
+			name:    "rejects a release signed with another key before downloading",
+			release: release(otherKey, digest[:]),
+			wantErr: "signature doesn't match",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranges = nil
+			ignoreRange = tt.ignoreRange
+
+			var progress []UpdateProgress
+			updater := &Updater{
+				StagingDir: t.TempDir(),
+				PublicKey:  publicKey,
+				Client:     server.Client(),
+				OnProgress: func(p UpdateProgress) { progress = append(progress, p) },
+			}
+
+			archivePath := filepath.Join(updater.StagingDir, "4.2.0", "Finicky.zip")
+			if tt.partial != nil {
+				os.MkdirAll(filepath.Dir(archivePath), 0755)
+				if err := os.WriteFile(archivePath+".part", tt.partial, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := updater.Download(context.Background(), tt.release)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Download() error = %v, want %q", err, tt.wantErr)
+				}
+				if last := progress[len(progress)-1]; last.State != UpdateFailed {
+					t.Errorf("last progress state = %q, want %q", last.State, UpdateFailed)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("Download() error = %v", err)
+				}
+				if got != archivePath {
+					t.Errorf("Download() = %q, want %q", got, archivePath)
+				}
+				if data, _ := os.ReadFile(got); !bytes.Equal(data, archive) {
+					t.Errorf("downloaded archive differs from the release")
+				}
+				last := progress[len(progress)-1]
+				if last.State != UpdateReady || last.Downloaded != int64(len(archive)) || last.Total != int64(len(archive)) {
+					t.Errorf("last progress = %+v, want ready with %d bytes", last, len(archive))
+				}
+			}
+
+			if strings.Join(ranges, ",") != strings.Join(tt.wantRanges, ",") {
+				t.Errorf("requested ranges = %q, want %q", ranges, tt.wantRanges)
+			}
+		})
+	}
+}
@@ -6,30 +6,84 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
-	"finicky/util"
-
 	"github.com/Masterminds/semver"
-	"github.com/dop251/goja"
 )
 
-const updateCheckInterval = 24 * time.Hour
+const (
+	updateCheckInterval = 24 * time.Hour
+	// updateRetryDelay is how long to wait after a failed check, doubling with each
+	// failure in a row up to updateCheckInterval
+	updateRetryDelay = 15 * time.Minute
+)
+
+// Update channels
+const (
+	ChannelStable = "stable"
+	ChannelBeta   = "beta"
+)
 
 type ReleaseInfo struct {
 	HasUpdate     bool   `json:"hasUpdate"`
 	LatestVersion string `json:"latestVersion"`
 	DownloadUrl   string `json:"downloadUrl"`
 	ReleaseUrl    string `json:"releaseUrl"`
+	// ReleaseNotes describe what changed in the latest version, in markdown
+	ReleaseNotes string `json:"releaseNotes,omitempty"`
+	// PublishedAt is when the latest version was released, in RFC 3339 format
+	PublishedAt string `json:"publishedAt,omitempty"`
+	// MinimumOSVersion is the oldest macOS version the latest version runs on
+	MinimumOSVersion string `json:"minimumOsVersion,omitempty"`
+	Prerelease       bool   `json:"prerelease,omitempty"`
+	// SHA256 is the hex encoded checksum of the archive at DownloadUrl
+	SHA256 string `json:"sha256,omitempty"`
+	// Signature is the base64 encoded ed25519 signature of the checksum
+	Signature string `json:"signature,omitempty"`
 }
 
 type UpdateCheckInfo struct {
+	// Timestamp is when the last successful check was made
 	Timestamp   int64       `json:"timestamp"`
+	Channel     string      `json:"channel,omitempty"`
+	APIHost     string      `json:"apiHost,omitempty"`
 	ReleaseInfo ReleaseInfo `json:"releaseInfo"`
+	// Failures counts the checks that failed since the last successful one
+	Failures int `json:"failures,omitempty"`
+	// LastFailure is when the last failed check was made
+	LastFailure int64  `json:"lastFailure,omitempty"`
+	LastError   string `json:"lastError,omitempty"`
+}
+
+// nextCheck returns when the next update check is due, backing off after failures
+func (info UpdateCheckInfo) nextCheck() time.Time {
+	if info.Failures > 0 {
+		return time.Unix(info.LastFailure, 0).Add(retryDelay(info.Failures))
+	}
+	return time.Unix(info.Timestamp, 0).Add(updateCheckInterval)
+}
+
+// retryDelay returns how long to wait before checking again after a number of failed checks
+func retryDelay(failures int) time.Duration {
+	delay := updateRetryDelay
+	for i := 1; i < failures && delay < updateCheckInterval; i++ {
+		delay *= 2
+	}
+	return min(delay, updateCheckInterval)
+}
+
+// UpdateSettings are the config options that control update checks
+type UpdateSettings struct {
+	Enabled bool
+	// Channel is ChannelStable or ChannelBeta, which also offers pre-releases
+	Channel string
+	// APIHost overrides the update API host set at build time
+	APIHost string
 }
 
 var (
@@ -37,6 +91,10 @@ var (
 	commitHash = "dev"
 	buildDate  = "unknown"
 	apiHost    = ""
+	// buildVersion overrides the version read from Info.plist
+	buildVersion = ""
+	// updatePublicKey is the base64 encoded ed25519 key releases are signed with
+	updatePublicKey = ""
 )
 
 // GetBuildInfo returns the commit hash and build date
@@ -45,7 +103,7 @@ func GetBuildInfo() (string, string) {
 }
 
 func getCacheDir() string {
-	cacheDir, err := util.UserCacheDir()
+	cacheDir, err := userCacheDir()
 	if err != nil {
 		slog.Error("Error getting user cache directory", "error", err)
 		return ""
@@ -96,7 +154,27 @@ func setLastUpdateCheck(info UpdateCheckInfo) {
 	}
 }
 
+// GetCurrentVersion returns the version of the running app, read once per process
+// from the buildVersion set at build time, or else from the CFBundleVersion of the
+// Info.plist at BUNDLE_PATH or in the app bundle. It returns an empty string when
+// the version can't be read.
 func GetCurrentVersion() string {
+	currentVersionOnce.Do(func() {
+		currentVersion = readCurrentVersion()
+	})
+	return currentVersion
+}
+
+var (
+	currentVersion     string
+	currentVersionOnce sync.Once
+)
+
+func readCurrentVersion() string {
+	if buildVersion != "" {
+		return buildVersion
+	}
+
 	// Get the bundle path
 	bundlePath := os.Getenv("BUNDLE_PATH")
 	if bundlePath == "" {
@@ -109,16 +187,12 @@ func GetCurrentVersion() string {
 		bundlePath = filepath.Join(filepath.Dir(execPath), "..", "Info.plist")
 	}
 
-	// Read and parse Info.plist
-	cmd := exec.Command("defaults", "read", bundlePath, "CFBundleVersion")
-	output, err := cmd.Output()
+	version, err := readBundleVersion(bundlePath)
 	if err != nil {
-		slog.Error("Error reading version from Info.plist", "error", err)
+		slog.Error("Error reading version from Info.plist", "path", bundlePath, "error", err)
 		return ""
 	}
 
-	version := strings.TrimSpace(string(output))
-
 	if version == "" {
 		slog.Error("Could not determine current version")
 		return "dev"
@@ -127,130 +201,164 @@ func GetCurrentVersion() string {
 	return version
 }
 
-func checkForUpdates() (releaseInfo *ReleaseInfo) {
+// readBundleVersion reads CFBundleVersion from an Info.plist, or from the
+// Info.plist of an app bundle or its Contents directory
+func readBundleVersion(path string) (string, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		if filepath.Base(filepath.Clean(path)) == "Contents" {
+			path = filepath.Join(path, "Info.plist")
+		} else {
+			path = filepath.Join(path, "Contents", "Info.plist")
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	version, err := readPlistString(data, "CFBundleVersion")
+	return strings.TrimSpace(version), err
+}
+
+func checkForUpdates(settings UpdateSettings) *ReleaseInfo {
 	currentVersion := GetCurrentVersion()
 	if currentVersion == "" {
 		slog.Info("Could not determine current version")
 		return nil
 	}
 
-	slog.Debug("Checking update schedule...")
-
-	updateCheckInfo := getLastUpdateCheck()
-	if updateCheckInfo != nil && updateCheckInfo.Timestamp > 0 {
-		timeSinceLastCheck := time.Since(time.Unix(updateCheckInfo.Timestamp, 0))
-		if timeSinceLastCheck < updateCheckInterval {
-			slog.Debug("Skipping update check - last checked", "duration", fmt.Sprintf("%dh %dm ago (check interval: %dh)", int(timeSinceLastCheck.Hours()), int(timeSinceLastCheck.Minutes())%60, int(updateCheckInterval.Hours())))
+	host := apiHost
+	if settings.APIHost != "" {
+		host = strings.TrimSuffix(settings.APIHost, "/")
+	}
+	channel := settings.Channel
+	if channel == "" {
+		channel = ChannelStable
+	}
 
-			if updateCheckInfo.ReleaseInfo.LatestVersion == "" {
-				slog.Debug("No latest version found from last check")
-				return nil
-			}
+	slog.Debug("Checking update schedule...")
 
-			updateAvailable, err := isUpdateAvailable(currentVersion, updateCheckInfo.ReleaseInfo.LatestVersion)
-			if err != nil {
-				slog.Warn("Error checking version", "error", err)
-				return nil
-			}
+	// A check for another channel or host says nothing about this one
+	lastCheck := getLastUpdateCheck()
+	if lastCheck != nil && (lastCheck.Channel != channel || lastCheck.APIHost != host) {
+		lastCheck = nil
+	}
 
-			if updateAvailable {
-				slog.Debug("Update available", "currentVersion", currentVersion, "latestVersion", updateCheckInfo.ReleaseInfo.LatestVersion)
-				return &updateCheckInfo.ReleaseInfo
+	if lastCheck != nil && (lastCheck.Timestamp > 0 || lastCheck.Failures > 0) {
+		if nextCheck := lastCheck.nextCheck(); time.Now().Before(nextCheck) {
+			if lastCheck.Failures > 0 {
+				slog.Debug("Skipping update check after failures", "failures", lastCheck.Failures, "lastError", lastCheck.LastError, "nextCheck", nextCheck.Format(time.RFC3339))
 			} else {
-				slog.Debug("Current version is up to date", "currentVersion", currentVersion, "latestVersion", updateCheckInfo.ReleaseInfo.LatestVersion)
-				return nil
+				timeSinceLastCheck := time.Since(time.Unix(lastCheck.Timestamp, 0))
+				slog.Debug("Skipping update check - last checked", "duration", fmt.Sprintf("%dh %dm ago (check interval: %dh)", int(timeSinceLastCheck.Hours()), int(timeSinceLastCheck.Minutes())%60, int(updateCheckInterval.Hours())))
 			}
+			return availableRelease(currentVersion, lastCheck, channel)
 		}
 	}
 
-	slog.Info("Checking for updates...")
+	if host == "" {
+		slog.Warn("apiHost is not set, won't check for updates")
+		return nil
+	}
+
+	// Being offline isn't a failure of the update API, so it doesn't back off
+	if isOffline(host) {
+		slog.Info("Skipping update check while offline")
+		return availableRelease(currentVersion, lastCheck, channel)
+	}
+
+	slog.Info("Checking for updates...", "channel", channel)
 
-	// Create HTTP client with timeout
-	client := &http.Client{
-		Timeout: 3 * time.Second,
+	releaseInfo, err := fetchReleaseInfo(host, currentVersion, channel)
+	if err != nil {
+		failedCheck := UpdateCheckInfo{Channel: channel, APIHost: host}
+		if lastCheck != nil {
+			failedCheck = *lastCheck
+		}
+		failedCheck.Failures++
+		failedCheck.LastFailure = time.Now().Unix()
+		failedCheck.LastError = err.Error()
+		setLastUpdateCheck(failedCheck)
+
+		slog.Warn("Update check failed", "error", err, "failures", failedCheck.Failures, "retryIn", retryDelay(failedCheck.Failures).String())
+		return availableRelease(currentVersion, lastCheck, channel)
 	}
 
-	if apiHost == "" {
-		slog.Warn("apiHost is not set, won't check for updates")
-		return nil
+	// Update the last check time
+	check := UpdateCheckInfo{
+		Timestamp:   time.Now().Unix(),
+		Channel:     channel,
+		APIHost:     host,
+		ReleaseInfo: *releaseInfo,
 	}
+	setLastUpdateCheck(check)
+
+	return availableRelease(currentVersion, &check, channel)
+}
 
-	// Create request
-	apiUrl := fmt.Sprintf("%s/update-check?version=%s", apiHost, currentVersion)
+// fetchReleaseInfo asks the update API for the latest release on a channel
+func fetchReleaseInfo(host string, currentVersion string, channel string) (*ReleaseInfo, error) {
+	client := newHTTPClient(3 * time.Second)
+
+	apiUrl := fmt.Sprintf("%s/update-check?version=%s&channel=%s", host, url.QueryEscape(currentVersion), url.QueryEscape(channel))
 	req, err := http.NewRequest("GET", apiUrl, nil)
 	if err != nil {
-		slog.Error("Error creating request", "error", err)
-		return nil
+		return nil, fmt.Errorf("error creating request: %v", err)
 	}
 
 	// Set User-Agent header
 	req.Header.Set("User-Agent", fmt.Sprintf("finicky/%s", currentVersion))
 
-	// Make request
 	resp, err := client.Do(req)
 	if err != nil {
-		slog.Error("Error making request", "error", err)
-		return nil
+		return nil, fmt.Errorf("error making request: %v", err)
 	}
 	defer resp.Body.Close()
 
-	// Read response
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("update API responded with %s", resp.Status)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		slog.Error("Error reading response", "error", err)
-		return nil
+		return nil, fmt.Errorf("error reading response: %v", err)
 	}
 
-	// Parse releases
+	var releaseInfo ReleaseInfo
 	if err := json.Unmarshal(body, &releaseInfo); err != nil {
-		slog.Error("Error parsing release info", "error", err, "response", string(body))
-		return nil
+		return nil, fmt.Errorf("error parsing release info: %v", err)
 	}
+	return &releaseInfo, nil
+}
 
-	// Update the last check time
-	setLastUpdateCheck(UpdateCheckInfo{
-		Timestamp:   time.Now().Unix(),
-		ReleaseInfo: *releaseInfo,
-	})
-
-	if releaseInfo.LatestVersion == "" {
+// availableRelease returns the release of an update check when it's newer than the
+// current version, or nil
+func availableRelease(currentVersion string, check *UpdateCheckInfo, channel string) *ReleaseInfo {
+	if check == nil || check.ReleaseInfo.LatestVersion == "" {
 		slog.Debug("No latest version found")
 		return nil
 	}
 
-	updateAvailable, err := isUpdateAvailable(currentVersion, releaseInfo.LatestVersion)
+	updateAvailable, err := isUpdateAvailable(currentVersion, check.ReleaseInfo.LatestVersion, channel)
 	if err != nil {
-		slog.Error("Error checking version", "error", err)
+		slog.Warn("Error checking version", "error", err)
 		return nil
 	}
 
-	if updateAvailable {
-		slog.Debug("Update available", "currentVersion", currentVersion, "latestVersion", releaseInfo.LatestVersion)
-	} else {
-		slog.Debug("Current version is up to date", "currentVersion", currentVersion, "latestVersion", releaseInfo.LatestVersion)
+	if !updateAvailable {
+		slog.Debug("Current version is up to date", "currentVersion", currentVersion, "latestVersion", check.ReleaseInfo.LatestVersion)
 		return nil
 	}
 
-	return releaseInfo
+	slog.Debug("Update available", "currentVersion", currentVersion, "latestVersion", check.ReleaseInfo.LatestVersion)
+	return &check.ReleaseInfo
 }
 
-// CheckForUpdatesIfEnabled checks if updates should be performed based on VM configuration
-func CheckForUpdatesIfEnabled(vm *goja.Runtime) (releaseInfo *ReleaseInfo, updateCheckEnabled bool, err error) {
-
-	if vm == nil {
-		// Check for updates if we don't have a VM
-		releaseInfo := checkForUpdates()
-		return releaseInfo, true, nil
-	}
-
-	// Check checkForUpdates option
-	shouldCheckForUpdates, err := vm.RunString("finickyConfigAPI.getOption('checkForUpdates', finalConfig, true)")
-	if err != nil {
-		return nil, true, fmt.Errorf("failed to get checkForUpdates option: %v", err)
-	}
-
-	if shouldCheckForUpdates.ToBoolean() {
-		releaseInfo := checkForUpdates()
+// CheckForUpdatesIfEnabled checks for updates when the checkForUpdates option is enabled
+func CheckForUpdatesIfEnabled(settings UpdateSettings) (releaseInfo *ReleaseInfo, updateCheckEnabled bool, err error) {
+	if settings.Enabled {
+		releaseInfo := checkForUpdates(settings)
 		return releaseInfo, true, nil
 	} else {
 		slog.Debug("Skipping update check")
@@ -259,7 +367,10 @@ func CheckForUpdatesIfEnabled(vm *goja.Runtime) (releaseInfo *ReleaseInfo, updat
 }
 
 // isUpdateAvailable checks if the latest version is newer than the current version
-func isUpdateAvailable(currentVersion, latestVersion string) (bool, error) {
+// and offered on the channel. Pre-releases are only offered on the beta channel, and
+// are ordered by their identifiers, so 1.0.0-beta.2 follows 1.0.0-beta.1 and
+// precedes 1.0.0.
+func isUpdateAvailable(currentVersion, latestVersion string, channel string) (bool, error) {
 
 	currentVersion = strings.TrimPrefix(currentVersion, "v")
 	latestVersion = strings.TrimPrefix(latestVersion, "v")
@@ -274,5 +385,9 @@ func isUpdateAvailable(currentVersion, latestVersion string) (bool, error) {
 		return false, fmt.Errorf("parsing latest version %s: %w", latestVersion, err)
 	}
 
+	if latestSemver.Prerelease() != "" && channel != ChannelBeta {
+		return false, nil
+	}
+
 	return latestSemver.GreaterThan(currentSemver), nil
 }
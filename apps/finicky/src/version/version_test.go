@@ -1,28 +1,37 @@
 package version
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestIsUpdateAvailable(t *testing.T) {
 	tests := []struct {
 		name     string
 		current  string
 		latest   string
+		channel  string
 		expected bool
 		wantErr  bool
 	}{
-		{"same version", "1.0.0", "1.0.0", false, false},
-		{"newer version available", "1.0.0", "1.0.1", true, false},
-		{"older version", "1.0.1", "1.0.0", false, false},
-		{"v-prefixed versions", "v1.0.0", "v1.0.1", true, false},
-		{"mixed prefixes", "1.0.0", "v1.0.1", true, false},
-		{"pre-release vs stable", "1.0.0-beta", "1.0.0", true, false},
-		{"invalid current version", "invalid", "1.0.0", false, true},
-		{"invalid latest version", "1.0.0", "invalid", false, true},
+		{"same version", "1.0.0", "1.0.0", ChannelStable, false, false},
+		{"newer version available", "1.0.0", "1.0.1", ChannelStable, true, false},
+		{"older version", "1.0.1", "1.0.0", ChannelStable, false, false},
+		{"v-prefixed versions", "v1.0.0", "v1.0.1", ChannelStable, true, false},
+		{"mixed prefixes", "1.0.0", "v1.0.1", ChannelStable, true, false},
+		{"pre-release vs stable", "1.0.0-beta", "1.0.0", ChannelStable, true, false},
+		{"pre-release on the stable channel", "1.0.0", "1.1.0-beta.1", ChannelStable, false, false},
+		{"pre-release on the beta channel", "1.0.0", "1.1.0-beta.1", ChannelBeta, true, false},
+		{"numeric pre-release identifiers", "1.1.0-beta.9", "1.1.0-beta.10", ChannelBeta, true, false},
+		{"pre-release before its release", "1.1.0", "1.1.0-rc.1", ChannelBeta, false, false},
+		{"release candidate after beta", "1.1.0-beta.2", "1.1.0-rc.1", ChannelBeta, true, false},
+		{"invalid current version", "invalid", "1.0.0", ChannelStable, false, true},
+		{"invalid latest version", "1.0.0", "invalid", ChannelStable, false, true},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := isUpdateAvailable(tt.current, tt.latest)
+			got, err := isUpdateAvailable(tt.current, tt.latest, tt.channel)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("isUpdateAvailable() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -33,3 +42,26 @@ func TestIsUpdateAvailable(t *testing.T) {
 		})
 	}
 }
+
+func TestNextCheck(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name     string
+		info     UpdateCheckInfo
+		expected time.Time
+	}{
+		{"after a successful check", UpdateCheckInfo{Timestamp: now.Unix()}, now.Add(24 * time.Hour)},
+		{"after one failure", UpdateCheckInfo{Timestamp: now.Unix() - 3600, Failures: 1, LastFailure: now.Unix()}, now.Add(15 * time.Minute)},
+		{"after three failures", UpdateCheckInfo{Failures: 3, LastFailure: now.Unix()}, now.Add(time.Hour)},
+		{"after many failures", UpdateCheckInfo{Failures: 40, LastFailure: now.Unix()}, now.Add(24 * time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.info.nextCheck(); !got.Equal(tt.expected) {
+				t.Errorf("nextCheck() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -29,11 +29,12 @@ var (
 	queueMutex                    sync.Mutex
 	webMessageCallbackDepth       atomic.Int32
 	windowReady                   bool
-	TestUrlHandler                func(string)
+	TestUrlHandler                func(url string, simulatedTime string)
 	EnableICloudSyncHandler       func() (interface{}, error)
 	DisableICloudSyncHandler      func() (interface{}, error)
 	GetICloudSyncStatusHandler    func() (interface{}, error)
 	GetChromiumProfilesHandler    func() (interface{}, error)
+	ClearShortURLCacheHandler     func() (interface{}, error)
 	GetConfigBuilderDataHandler   func() (interface{}, error)
 	PreviewGeneratedConfigHandler func(map[string]interface{}) (interface{}, error)
 	SaveGeneratedConfigHandler    func(map[string]interface{}) (interface{}, error)
@@ -201,6 +202,8 @@ func handleWebViewMessageInternal(messageStr string) {
 		handleGetICloudSyncStatus()
 	case "getChromiumProfiles":
 		handleGetChromiumProfiles()
+	case "clearShortUrlCache":
+		handleClearShortURLCache()
 	case "getConfigBuilderData":
 		handleGetConfigBuilderData()
 	case "previewGeneratedConfig":
@@ -232,10 +235,13 @@ func handleTestUrl(msg map[string]interface{}) {
 		return
 	}
 
-	slog.Debug("Forwarding test URL request", "url", url)
+	// Optional RFC 3339 time to evaluate time based helpers against
+	simulatedTime, _ := msg["time"].(string)
+
+	slog.Debug("Forwarding test URL request", "url", url, "time", simulatedTime)
 
 	if TestUrlHandler != nil {
-		TestUrlHandler(url)
+		TestUrlHandler(url, simulatedTime)
 	} else {
 		slog.Error("TestUrlHandler not set")
 		SendMessageToWebView("testUrlResult", map[string]interface{}{
@@ -289,6 +295,27 @@ func handleGetChromiumProfiles() {
 	})
 }
 
+func handleClearShortURLCache() {
+	if ClearShortURLCacheHandler == nil {
+		SendMessageToWebView("shortUrlCacheResult", map[string]interface{}{
+			"ok":    false,
+			"error": "Short URL cache handler not initialized",
+		})
+		return
+	}
+
+	result, err := ClearShortURLCacheHandler()
+	if err != nil {
+		SendMessageToWebView("shortUrlCacheResult", map[string]interface{}{
+			"ok":    false,
+			"error": err.Error(),
+		})
+		return
+	}
+
+	SendMessageToWebView("shortUrlCacheResult", result)
+}
+
 func handleDisableICloudSync() {
 	if DisableICloudSyncHandler == nil {
 		SendMessageToWebView("cloudSyncResult", map[string]interface{}{
@@ -31,6 +31,24 @@ interface FinickyUtils {
         percentage: number | null;
     };
     isAppRunning: (identifier: string) => boolean;
+    now: (timezone?: string) => {
+        date: Date;
+        year: number;
+        month: number;
+        day: number;
+        weekday: "sun" | "mon" | "tue" | "wed" | "thu" | "fri" | "sat";
+        hour: number;
+        minute: number;
+        second: number;
+        timezone: string;
+    };
+    isWithinSchedule: (schedule: {
+        days?: string[];
+        from?: string;
+        to?: string;
+        tz?: string;
+    }) => boolean;
+    resolveUrl: (url: string) => string;
 }
 
 declare global {
@@ -1,6 +1,12 @@
 import { describe, it, expect, vi } from "vitest";
-import { openUrl } from "./index";
-import { Config, ProcessInfo } from "./configSchema";
+import {
+  openUrl,
+  mergeConfigFragments,
+  getConfigProblems,
+  getBrowserReferences,
+  getLintFindings,
+} from "./index";
+import { Config, OpenUrlOptions, ProcessInfo } from "./configSchema";
 
 describe("openUrl", () => {
   const mockProcessInfo: ProcessInfo = {
@@ -63,6 +69,30 @@ describe("openUrl", () => {
       const result = openUrl("https://example.com", null, null, handlerConfig);
       expect(result.browser).toMatchObject({ name: "Firefox" });
     });
+
+    it("passes the redirect chain to matchers", () => {
+      const ssoConfig = {
+        defaultBrowser: "Safari",
+        handlers: [
+          {
+            match: (_url: URL, { redirectChain }: OpenUrlOptions) =>
+              (redirectChain ?? []).some((hop) => hop.host === "sso.example.com"),
+            browser: "Firefox",
+          },
+        ],
+      };
+      const redirectChain = [
+        { url: "https://sso.example.com/login", status: 302, host: "sso.example.com" },
+        { url: "https://example.com", status: 200, host: "example.com" },
+      ];
+
+      expect(
+        openUrl("https://example.com", null, null, ssoConfig, redirectChain).browser
+      ).toMatchObject({ name: "Firefox" });
+      expect(
+        openUrl("https://example.com", null, null, ssoConfig).browser
+      ).toMatchObject({ name: "Safari" });
+    });
   });
 
   describe("rewrites", () => {
@@ -180,3 +210,172 @@ describe("openUrl", () => {
     });
   });
 });
+
+describe("mergeConfigFragments", () => {
+  const fragments = [
+    {
+      name: "10-company.js",
+      config: {
+        defaultBrowser: "Safari",
+        handlers: [{ match: "*.company.com*", browser: "Google Chrome" }],
+        options: { checkForUpdates: false },
+      },
+    },
+    {
+      name: "20-personal.js",
+      config: {
+        defaultBrowser: "Firefox",
+        handlers: [{ match: "github.com*", browser: "Arc" }],
+        options: { keepRunning: false },
+      },
+    },
+  ];
+
+  it("merges fragments in order", () => {
+    const merged = mergeConfigFragments(fragments);
+    expect(merged.defaultBrowser).toBe("Firefox");
+    expect(merged.handlers).toHaveLength(2);
+    expect(merged.options).toEqual({ checkForUpdates: false, keepRunning: false });
+  });
+
+  it("reports the fragment of the matched handler", () => {
+    const merged = mergeConfigFragments(fragments);
+    const result = openUrl("https://wiki.company.com/page", null, null, merged);
+    expect(result.browser).toMatchObject({ name: "Google Chrome" });
+    expect(result.matchedHandler).toEqual({ index: 0, source: "10-company.js" });
+  });
+
+  it("warns about conflicting default browsers", () => {
+    const warn = vi.spyOn(console, "warn").mockImplementation(() => {});
+    mergeConfigFragments(fragments);
+    expect(warn).toHaveBeenCalledWith(
+      "defaultBrowser in 20-personal.js overrides the one from 10-company.js"
+    );
+    warn.mockRestore();
+  });
+});
+
+describe("getConfigProblems", () => {
+  it("returns nothing for a valid config", () => {
+    expect(getConfigProblems({ defaultBrowser: "Safari" })).toEqual([]);
+  });
+
+  it("returns the path of every problem", () => {
+    const problems = getConfigProblems({
+      defaultBrowser: "Safari",
+      handlers: [{ match: "example.com*", browser: 5 }],
+      options: { logRequests: "yes" },
+    });
+    expect(problems.map((problem) => problem.path)).toEqual([
+      ["options", "logRequests"],
+      ["handlers", 0, "browser"],
+    ]);
+  });
+
+  it("locates handlers in the fragment they came from", () => {
+    const merged = mergeConfigFragments([
+      { name: "10-a.js", config: { defaultBrowser: "Safari", handlers: [{ match: "a.com", browser: "Firefox" }] } },
+      { name: "20-b.js", config: { handlers: [{ match: 5, browser: "Firefox" } as any] } },
+    ]);
+    expect(getConfigProblems(merged)).toMatchObject([
+      { path: ["handlers", 0, "match"], source: "20-b.js" },
+    ]);
+  });
+});
+
+describe("getBrowserReferences", () => {
+  it("lists static browsers with their profiles", () => {
+    const references = getBrowserReferences({
+      defaultBrowser: "Google Chrome:Work",
+      handlers: [
+        { match: "a.com", browser: { name: "com.brave.Browser", appType: "bundleId" } },
+        { match: "b.com", browser: () => "Firefox" },
+        { match: "c.com", browser: null },
+      ],
+    });
+    expect(references).toEqual([
+      { path: ["defaultBrowser"], name: "Google Chrome", appType: "appName", profile: "Work" },
+      { path: ["handlers", 0, "browser"], name: "com.brave.Browser", appType: "bundleId", profile: "" },
+    ]);
+  });
+});
+
+describe("getLintFindings", () => {
+  const tests: Array<{ name: string; config: Config; expected: unknown[] }> = [
+    {
+      name: "handlers after a catch-all",
+      config: {
+        defaultBrowser: "Safari",
+        handlers: [
+          { match: "a.com", browser: "Firefox" },
+          { match: /.*/, browser: "Firefox" },
+          { match: "b.com", browser: "Google Chrome" },
+        ],
+      },
+      expected: [
+        {
+          path: ["handlers", 2],
+          message: "never matches because handlers[1] matches every url",
+        },
+      ],
+    },
+    {
+      name: "duplicate patterns",
+      config: {
+        defaultBrowser: "Safari",
+        handlers: [
+          { match: ["a.com", /b\.com/], browser: "Firefox" },
+          { match: /b\.com/, browser: "Google Chrome" },
+          { match: ["c.com", "c.com"], browser: "Google Chrome" },
+        ],
+      },
+      expected: [
+        {
+          path: ["handlers", 1, "match"],
+          message:
+            "duplicate pattern /b\\.com/, urls it matches already open with handlers[0]",
+        },
+        {
+          path: ["handlers", 2, "match", 1],
+          message: 'duplicate pattern "c.com"',
+        },
+      ],
+    },
+    {
+      name: "rewrites that no handler picks up",
+      config: {
+        defaultBrowser: "Safari",
+        rewrite: [
+          { match: "x.com", url: "https://a.com/page" },
+          { match: "y.com", url: "https://z.com/" },
+          { match: "z.com", url: (url) => url },
+        ],
+        handlers: [{ match: "a.com*", browser: "Firefox" }],
+      },
+      expected: [
+        {
+          path: ["rewrite", 1, "url"],
+          message:
+            "rewritten url https://z.com/ matches no handler and opens in the default browser",
+        },
+      ],
+    },
+    {
+      name: "a config without mistakes",
+      config: {
+        defaultBrowser: "Safari",
+        handlers: [
+          { match: "a.com", browser: "Firefox" },
+          { match: "*", browser: "Google Chrome" },
+        ],
+      },
+      expected: [],
+    },
+  ];
+
+  tests.forEach(({ name, config, expected }) => {
+    it(name, () => {
+      expect(getLintFindings(config)).toEqual(expected);
+    });
+  });
+});
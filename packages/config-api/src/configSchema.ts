@@ -36,10 +36,28 @@ const ProcessInfoSchema = z
 
 export type ProcessInfo = z.infer<typeof ProcessInfoSchema>;
 
+const RedirectHopSchema = z
+  .object({
+    url: z.string(),
+    status: z
+      .number()
+      .describe("The HTTP status the url answered with, 0 when it wasn't requested"),
+    host: z.string(),
+  })
+  .identifier("RedirectHop");
+
+export type RedirectHop = z.infer<typeof RedirectHopSchema>;
+
 const OpenUrlOptionsSchema = z
   .object({
     opener: ProcessInfoSchema.nullable(),
     originalUrl: NativeUrlSchema.optional(),
+    redirectChain: z
+      .array(RedirectHopSchema)
+      .optional()
+      .describe(
+        "The urls the url passed through before the config saw it, from the original url to the resolved one"
+      ),
   })
   .identifier("OpenUrlOptions");
 
@@ -130,11 +148,154 @@ const HandlerRuleSchema = z
 // ===== Configuration Schemas =====
 const ConfigOptionsSchema = z
   .object({
-    urlShorteners: z.array(z.string()).optional(),
+    urlShorteners: z
+      .array(z.string())
+      .optional()
+      .describe("Extra domains whose urls are resolved before matching"),
+    disabledUrlShorteners: z
+      .array(z.string())
+      .optional()
+      .describe("Domains whose urls are never resolved, even built-in ones"),
+    shortenerRegistry: z
+      .string()
+      .optional()
+      .describe(
+        "A file path or url of a JSON list of extra shortener domains, downloaded again daily"
+      ),
     logRequests: z.boolean().optional().describe("Log to file on disk"),
     checkForUpdates: z.boolean().optional().describe("Check for updates"),
+    updateChannel: z
+      .enum(["stable", "beta"])
+      .optional()
+      .describe('Which releases to be offered, "beta" includes pre-releases'),
+    updateApiHost: z
+      .string()
+      .url()
+      .optional()
+      .describe("The host to check for updates with, instead of the default one"),
     keepRunning: z.boolean().optional().describe("Keep the app running"),
-    hideIcon: z.boolean().optional().describe("Hide the app icon")
+    hideIcon: z.boolean().optional().describe("Hide the app icon"),
+    logLevel: z
+      .enum(["debug", "info", "warn", "error"])
+      .optional()
+      .describe("The least severe level of log messages to keep"),
+    logStdoutFormat: z
+      .enum(["json", "text"])
+      .optional()
+      .describe("The format of the logs written to stdout"),
+    logFileFormat: z
+      .enum(["json", "text"])
+      .optional()
+      .describe("The format of the logs written to the log file"),
+    logWindowFormat: z
+      .enum(["json", "text"])
+      .optional()
+      .describe("The format of the logs shown in the window"),
+    logRedaction: z
+      .enum(["params", "hosts", "off"])
+      .optional()
+      .describe(
+        'How urls are masked in logs: "params" masks sensitive query parameters, credentials and fragments, "hosts" only logs hosts'
+      ),
+    logRedactedParams: z
+      .array(z.string())
+      .optional()
+      .describe(
+        'Extra query parameters to mask in logs, a "*" matches any characters'
+      ),
+    logFileMaxSizeMb: z
+      .number()
+      .int()
+      .nonnegative()
+      .optional()
+      .describe("The size in megabytes a log file is rotated at. 0 means no limit"),
+    logFileMaxAgeHours: z
+      .number()
+      .int()
+      .nonnegative()
+      .optional()
+      .describe("How many hours a log file is written to before it's rotated. 0 means no limit"),
+    logRetentionCount: z
+      .number()
+      .int()
+      .nonnegative()
+      .optional()
+      .describe("How many rotated log files to keep. 0 means no limit"),
+    logRetentionSizeMb: z
+      .number()
+      .int()
+      .nonnegative()
+      .optional()
+      .describe("How many megabytes of rotated log files to keep. 0 means no limit"),
+    shortUrlTimeoutMs: z
+      .number()
+      .int()
+      .nonnegative()
+      .optional()
+      .describe(
+        "How long to wait for a short url to resolve, in milliseconds. 0 means no limit"
+      ),
+    shortUrlMaxRedirects: z
+      .number()
+      .int()
+      .nonnegative()
+      .optional()
+      .describe("How many redirects to follow when resolving a short url"),
+    shortUrlMethods: z
+      .array(z.enum(["HEAD", "GET"]))
+      .nonempty()
+      .optional()
+      .describe("HTTP methods to try in order when resolving a short url"),
+    shortUrlUserAgent: z
+      .string()
+      .optional()
+      .describe("The user agent sent to url shorteners"),
+    shortUrlHeaders: z
+      .record(z.string(), z.record(z.string(), z.string()))
+      .optional()
+      .describe(
+        'Extra headers sent to url shorteners by domain, use "*" for every domain'
+      ),
+    shortUrlCacheSize: z
+      .number()
+      .int()
+      .nonnegative()
+      .optional()
+      .describe("How many resolved short urls to keep on disk. 0 disables the cache"),
+    shortUrlResolution: z
+      .enum(["eager", "lazy"])
+      .optional()
+      .describe(
+        'When to resolve short urls: "eager" before the config runs, or "lazy" only when the config calls finicky.resolveUrl'
+      ),
+    skipShortUrlResolutionFor: z
+      .array(z.string())
+      .optional()
+      .describe(
+        "App names or bundle ids of openers whose urls are never resolved over the network"
+      ),
+    stripTrackingParams: z
+      .boolean()
+      .optional()
+      .describe("Remove tracking parameters like utm_source from urls"),
+    trackingParams: z
+      .array(z.string())
+      .optional()
+      .describe(
+        'Extra tracking parameters to remove, a "*" at the end matches any parameter starting with the rest'
+      ),
+    trackingParamExceptions: z
+      .record(z.string(), z.array(z.string()))
+      .optional()
+      .describe("Tracking parameters to keep, by domain"),
+    evaluationTimeoutMs: z
+      .number()
+      .int()
+      .nonnegative()
+      .optional()
+      .describe(
+        "How long the config may take to choose a browser, in milliseconds. 0 means no limit"
+      ),
   })
   .identifier("ConfigOptions");
 
@@ -4,6 +4,7 @@ import {
   ConfigSchema,
   Config,
   OpenUrlOptions,
+  RedirectHop,
   ProcessInfo,
   BrowserSpecificationSchema,
   BrowserSpecification,
@@ -12,10 +13,12 @@ import {
   BrowserConfig,
   BrowserConfigStrict,
   AppType,
+  HandlerRule,
+  RewriteRule,
 } from "./configSchema";
 import * as utilities from "./utilities";
 import { matchWildcard } from "./wildcard";
-import { fromError } from "zod-validation-error";
+import { fromError, fromZodIssue } from "zod-validation-error";
 import { isLegacyURLObject, legacyURLObjectToString } from "./legacyURLObject";
 import { FinickyURL } from "./FinickyURL";
 export { utilities };
@@ -58,6 +61,73 @@ export function getConfiguration(namespace: string): Config {
   );
 }
 
+/**
+ * A partial configuration exported by one file in a config directory (conf.d)
+ */
+export interface ConfigFragment {
+  name: string;
+  config: Partial<Config> | undefined;
+}
+
+/**
+ * The file a handler or rewrite rule was loaded from, when loaded from a config directory
+ */
+type SourceTagged = { source?: string };
+
+/**
+ * Merges config fragments in order. Handlers and rewrites are concatenated and tagged
+ * with the fragment they came from, options are merged and later fragments win.
+ */
+export function mergeConfigFragments(fragments: ConfigFragment[]): Config {
+  const merged: Config = {
+    defaultBrowser: "com.apple.Safari",
+    handlers: [],
+    rewrite: [],
+  };
+  let defaultBrowserSource: string | undefined;
+
+  for (const { name, config: fragmentConfig } of fragments) {
+    if (!fragmentConfig || typeof fragmentConfig !== "object") {
+      console.warn(`Config fragment ${name} has no default export, skipping it`);
+      continue;
+    }
+
+    const fragment = (fragmentConfig as any).default ?? fragmentConfig;
+
+    if ("defaultBrowser" in fragment) {
+      if (defaultBrowserSource) {
+        console.warn(
+          `defaultBrowser in ${name} overrides the one from ${defaultBrowserSource}`
+        );
+      }
+      merged.defaultBrowser = fragment.defaultBrowser;
+      defaultBrowserSource = name;
+    }
+
+    if (Array.isArray(fragment.handlers)) {
+      for (const handler of fragment.handlers) {
+        merged.handlers!.push({ ...handler, source: name } as HandlerRule);
+      }
+    }
+
+    if (Array.isArray(fragment.rewrite)) {
+      for (const rewrite of fragment.rewrite) {
+        merged.rewrite!.push({ ...rewrite, source: name } as RewriteRule);
+      }
+    }
+
+    if (fragment.options) {
+      merged.options = { ...merged.options, ...fragment.options };
+    }
+  }
+
+  if (!defaultBrowserSource) {
+    console.warn("No config fragment sets defaultBrowser, using Safari");
+  }
+
+  return merged;
+}
+
 export function getOption<T, K extends keyof Config["options"]>(
   option: keyof Config["options"],
   config: Config,
@@ -79,11 +149,241 @@ export function getConfigState(config: Config) {
   };
 }
 
+/**
+ * A problem found when checking a config. The path points into the config
+ * object, and for config directories into the fragment named by source.
+ */
+export interface ConfigProblem {
+  path: (string | number)[];
+  source?: string;
+  message: string;
+}
+
+/**
+ * A browser the config refers to by name, bundle id or path
+ */
+export interface BrowserReference {
+  path: (string | number)[];
+  source?: string;
+  name: string;
+  appType: AppType;
+  profile: string;
+}
+
+export function getConfigProblems(config: object): ConfigProblem[] {
+  if (!config) {
+    return [
+      {
+        path: [],
+        message:
+          "Could not find configuration object, please check your config file and make sure it has a default export.",
+      },
+    ];
+  }
+
+  const result = ConfigSchema.safeParse(config);
+  if (result.success) {
+    return [];
+  }
+
+  return result.error.issues.map((issue) => ({
+    ...locateRule(config as Config, issue.path),
+    message: fromZodIssue(issue, { prefix: null, includePath: false }).message,
+  }));
+}
+
+export function getBrowserReferences(config: Config): BrowserReference[] {
+  const references: BrowserReference[] = [];
+
+  const addReference = (
+    browser: BrowserSpecification,
+    path: (string | number)[]
+  ) => {
+    // Browsers chosen by functions are only known when a url is opened
+    if (
+      typeof browser === "function" ||
+      !BrowserSpecificationSchema.safeParse(browser).success
+    ) {
+      return;
+    }
+
+    const { name, appType, profile } = createBrowserConfig(browser);
+    if (appType === "none") {
+      return;
+    }
+
+    references.push({ ...locateRule(config, path), name, appType, profile });
+  };
+
+  addReference(config.defaultBrowser, ["defaultBrowser"]);
+  config.handlers?.forEach((handler, index) =>
+    addReference(handler.browser, ["handlers", index, "browser"])
+  );
+
+  return references;
+}
+
+// Regular expression sources that match any url
+const catchAllRegexSources = [".*", "^.*", ".*$", "^.*$", ".+", "^.+$", "(?:)"];
+
+/**
+ * Reports whether a matcher matches every url, so no handler after it can match
+ */
+function isCatchAllMatcher(match: UrlMatcherPattern): boolean {
+  if (Array.isArray(match)) {
+    return match.some(isCatchAllMatcher);
+  }
+  if (typeof match === "string") {
+    return /^\*+$/.test(match.trim());
+  }
+  if (match instanceof RegExp) {
+    return catchAllRegexSources.includes(match.source);
+  }
+  return false;
+}
+
+/**
+ * Returns a key identifying a static matcher pattern, or undefined for functions
+ */
+function patternKey(match: unknown): string | undefined {
+  if (typeof match === "string") {
+    return match.trim() ? `"${match.trim()}"` : undefined;
+  }
+  if (match instanceof RegExp) {
+    return match.toString();
+  }
+  return undefined;
+}
+
+/**
+ * Finds parts of a valid config that are most likely mistakes: handlers that
+ * can never match, patterns repeated across handlers and rewrites whose result
+ * isn't picked up by any handler
+ */
+export function getLintFindings(config: Config): ConfigProblem[] {
+  const findings: ConfigProblem[] = [];
+  const handlers = config.handlers ?? [];
+
+  const addFinding = (path: (string | number)[], message: string) => {
+    findings.push({ ...locateRule(config, path), message });
+  };
+
+  const describeHandler = (index: number) => {
+    const { path, source } = locateRule(config, ["handlers", index]);
+    const name = `handlers[${path[1]}]`;
+    return source ? `${name} in ${source}` : name;
+  };
+
+  const catchAll = handlers.findIndex((handler) =>
+    isCatchAllMatcher(handler.match)
+  );
+  if (catchAll >= 0) {
+    for (let index = catchAll + 1; index < handlers.length; index++) {
+      addFinding(
+        ["handlers", index],
+        `never matches because ${describeHandler(catchAll)} matches every url`
+      );
+    }
+  }
+
+  const seenPatterns = new Map<string, number>();
+  const lastHandler = catchAll >= 0 ? catchAll : handlers.length - 1;
+  for (let index = 0; index <= lastHandler; index++) {
+    const match = handlers[index].match;
+    const patterns = Array.isArray(match) ? match : [match];
+
+    patterns.forEach((pattern, patternIndex) => {
+      const key = patternKey(pattern);
+      if (!key) {
+        return;
+      }
+
+      const path: (string | number)[] = Array.isArray(match)
+        ? ["handlers", index, "match", patternIndex]
+        : ["handlers", index, "match"];
+
+      const first = seenPatterns.get(key);
+      if (first === undefined) {
+        seenPatterns.set(key, index);
+      } else if (first === index) {
+        addFinding(path, `duplicate pattern ${key}`);
+      } else {
+        addFinding(
+          path,
+          `duplicate pattern ${key}, urls it matches already open with ${describeHandler(first)}`
+        );
+      }
+    });
+  }
+
+  if (handlers.length > 0) {
+    config.rewrite?.forEach((rule, index) => {
+      if (typeof rule.url !== "string") {
+        return;
+      }
+
+      let url: URL;
+      try {
+        url = new URL(rule.url);
+      } catch {
+        return;
+      }
+
+      // Function matchers may depend on the opener, so they count as matching
+      const matched = handlers.some((handler) => {
+        const patterns = Array.isArray(handler.match)
+          ? handler.match
+          : [handler.match];
+        return (
+          patterns.some((pattern) => typeof pattern === "function") ||
+          isMatch(handler.match, url, { opener: null })
+        );
+      });
+
+      if (!matched) {
+        addFinding(
+          ["rewrite", index, "url"],
+          `rewritten url ${rule.url} matches no handler and opens in the default browser`
+        );
+      }
+    });
+  }
+
+  return findings;
+}
+
+/**
+ * Maps a path to a handler or rewrite rule merged from a config directory back
+ * to its position in the fragment it came from
+ */
+function locateRule(
+  config: Config,
+  path: (string | number)[]
+): { path: (string | number)[]; source?: string } {
+  const [list, index, ...rest] = path;
+  if ((list !== "handlers" && list !== "rewrite") || typeof index !== "number") {
+    return { path };
+  }
+
+  const rules = config[list] as SourceTagged[] | undefined;
+  const source = rules?.[index]?.source;
+  if (!rules || !source) {
+    return { path };
+  }
+
+  const localIndex = rules
+    .slice(0, index)
+    .filter((rule) => rule?.source === source).length;
+
+  return { path: [list, localIndex, ...rest], source };
+}
+
 export function openUrl(
   urlString: string,
   opener: ProcessInfo | null,
   originalUrlString: string | null,
-  config: object
+  config: object,
+  redirectChain: RedirectHop[] = []
 ) {
   try {
   if (!validateConfig(config)) {
@@ -94,6 +394,7 @@ export function openUrl(
 
   const options: OpenUrlOptions = {
     opener: opener,
+    redirectChain,
   };
 
   if (originalUrlString) {
@@ -101,6 +402,7 @@ export function openUrl(
   }
 
   let error: string | undefined;
+  let errorStack: string | undefined;
 
   try {
     if (config.rewrite) {
@@ -116,12 +418,17 @@ export function openUrl(
         if (isMatch(handler.match, url, options)) {
           return {
             browser: resolveBrowser(handler.browser, url, options),
+            matchedHandler: {
+              index,
+              source: (handler as SourceTagged).source,
+            },
           };
         }
       }
     }
   } catch (ex: unknown) {
     error = ex instanceof Error ? ex.message : String(ex);
+    errorStack = ex instanceof Error ? ex.stack : undefined;
   }
 
   const browser = resolveBrowser(config.defaultBrowser, url, options);
@@ -129,9 +436,10 @@ export function openUrl(
   return {
     browser,
     error,
+    errorStack,
   };
   } catch (ex: unknown) {
-    throw new Error(
+    const error = new Error(
       JSON.stringify(
         {
           message: "Failed to open URL",
@@ -142,6 +450,9 @@ export function openUrl(
         2
       )
     );
+    // Keeps the stack of the config code that threw, which the app maps back to the config files
+    (error as any).configStack = ex instanceof Error ? ex.stack : undefined;
+    throw error;
   }
 }
 
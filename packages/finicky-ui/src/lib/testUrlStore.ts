@@ -1,10 +1,19 @@
 import { writable } from 'svelte/store';
 
+export interface RedirectHop {
+  url: string;
+  status: number;
+  host: string;
+}
+
 export interface TestUrlResult {
   browser: string;
   url: string;
   openInBackground: boolean;
   profile?: string;
+  strippedParams?: string[];
+  redirectChain?: RedirectHop[];
+  error?: string;
 }
 
 export const testUrlResult = writable<TestUrlResult | null>(null);
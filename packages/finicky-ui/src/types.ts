@@ -28,6 +28,19 @@ export interface UpdateInfo {
   updateCheckEnabled: boolean;
   downloadUrl: string;
   releaseUrl: string;
+  releaseNotes?: string;
+  publishedAt?: string;
+  minimumOsVersion?: string;
+  prerelease?: boolean;
+}
+
+export interface UpdateProgress {
+  version: string;
+  state: "downloading" | "ready" | "failed";
+  downloaded: number;
+  total: number;
+  path?: string;
+  error?: string;
 }
 
 export interface ConfigOptions {
@@ -37,11 +50,19 @@ export interface ConfigOptions {
   checkForUpdates: boolean;
 }
 
+export interface ConfigWarning {
+  file: string;
+  line: number;
+  column: number;
+  message: string;
+}
+
 export interface ConfigInfo {
   configPath: string;
   handlers?: number;
   rewrites?: number;
   defaultBrowser?: string;
+  warnings?: ConfigWarning[];
   options?: {
     keepRunning?: boolean;
     hideIcon?: boolean;
@@ -50,6 +71,11 @@ export interface ConfigInfo {
   };
 }
 
+export interface ConfigReloadStatus {
+  error?: string;
+  previousConfigSince?: string;
+}
+
 export interface CloudSyncResult {
   ok: boolean;
   provider?: string;
@@ -60,6 +86,12 @@ export interface CloudSyncResult {
   error?: string;
 }
 
+export interface ShortUrlCacheResult {
+  ok: boolean;
+  message?: string;
+  error?: string;
+}
+
 export interface CloudSyncStatus {
   enabled: boolean;
   provider?: string;
@@ -65,3 +65,35 @@ export function formatLogEntry(entry: LogEntry): TextPart[] {
 
   return splitTextAndUrls(result);
 }
+
+/**
+ * Parses a log line written in either the JSON or the text format
+ * @param line The log line to parse
+ * @returns The log entry, with the whole line as its message when it can't be parsed
+ */
+export function parseLogLine(line: string): LogEntry {
+  try {
+    return JSON.parse(line);
+  } catch {
+    // Not JSON, so it's in the key=value text format
+  }
+
+  const entry: LogEntry = { level: "INFO", msg: line, time: "" };
+  const fieldRegex = /([^\s=]+)=("(?:[^"\\]|\\.)*"|\S*)/g;
+  let match;
+
+  while ((match = fieldRegex.exec(line)) !== null) {
+    const [, key, rawValue] = match;
+    let value = rawValue;
+    if (rawValue.startsWith('"')) {
+      try {
+        value = JSON.parse(rawValue);
+      } catch {
+        value = rawValue.slice(1, -1);
+      }
+    }
+    entry[key] = value;
+  }
+
+  return entry;
+}
@@ -73,6 +73,8 @@ fi
     COMMIT_HASH=$(git rev-parse --short HEAD)
     BUILD_DATE=$(date -u '+%Y-%m-%d %H:%M:%S UTC')
     API_HOST=$(cat .env | grep API_HOST | cut -d '=' -f 2)
+    # Base64 keys end in =, so everything after the first = is kept
+    UPDATE_PUBLIC_KEY=$(cat .env | grep UPDATE_PUBLIC_KEY | cut -d '=' -f 2-)
     GOCACHE_PATH="$(pwd)/apps/finicky/build-cache/go-build"
     SWIFT_LIB_PATH="/Applications/Xcode.app/Contents/Developer/Toolchains/XcodeDefault.xctoolchain/usr/lib/swift/macosx"
     SWIFT_SDK_LIB_PATH="/Applications/Xcode.app/Contents/Developer/Platforms/MacOSX.platform/Developer/SDKs/MacOSX.sdk/usr/lib/swift"
@@ -91,7 +93,8 @@ fi
         -ldflags \
         "-X 'finicky/version.commitHash=${COMMIT_HASH}' \
         -X 'finicky/version.buildDate=${BUILD_DATE}' \
-        -X 'finicky/version.apiHost=${API_HOST}'" \
+        -X 'finicky/version.apiHost=${API_HOST}' \
+        -X 'finicky/version.updatePublicKey=${UPDATE_PUBLIC_KEY}'" \
         -o ../build/${APP_NAME}/Contents/MacOS/Finicky
 )
 